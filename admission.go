@@ -0,0 +1,37 @@
+package broker
+
+import "sync"
+
+// admission admits publishers one at a time in the order they arrived, like a ticket queue.
+type admission struct {
+	mutex   sync.Mutex
+	waiters []chan void
+}
+
+// enter queues a publisher, and returns a channel that is closed when the publisher is admitted.
+func (admission *admission) enter() chan void {
+	turn := make(chan void)
+	admission.mutex.Lock()
+	defer admission.mutex.Unlock()
+	admission.waiters = append(admission.waiters, turn)
+	if len(admission.waiters) == 1 {
+		close(turn)
+	}
+	return turn
+}
+
+// leave removes a publisher from the queue, and admits the next publisher if the leaving one was admitted.
+func (admission *admission) leave(turn chan void) {
+	admission.mutex.Lock()
+	defer admission.mutex.Unlock()
+	for i, waiter := range admission.waiters {
+		if waiter != turn {
+			continue
+		}
+		admission.waiters = append(admission.waiters[:i], admission.waiters[i+1:]...)
+		if i == 0 && len(admission.waiters) > 0 {
+			close(admission.waiters[0])
+		}
+		return
+	}
+}
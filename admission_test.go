@@ -0,0 +1,64 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdmission(t *testing.T) {
+	assertions := assert.New(t)
+
+	var admission admission
+	first, second, third := admission.enter(), admission.enter(), admission.enter()
+	assertions.True(isClosed(first))
+	assertions.False(isClosed(second))
+
+	// a waiting publisher leaving on timeout does not admit the next one
+	admission.leave(second)
+	assertions.False(isClosed(third))
+
+	admission.leave(first)
+	assertions.True(isClosed(third))
+	admission.leave(third)
+	assertions.Empty(admission.waiters)
+}
+
+func TestFairPublishing(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().BufferSize(0).FairPublishing().Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// block the broker loop while it sends the first message
+	assertions.Nil(broker.Publish(0))
+	time.Sleep(50 * time.Millisecond)
+	errs := make(chan error)
+	for msg := 1; msg <= 5; msg++ {
+		go func(msg int) {
+			errs <- broker.Publish(msg)
+		}(msg)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for msg := 0; msg <= 5; msg++ {
+		assertions.Equal(msg, <-client)
+	}
+	for msg := 1; msg <= 5; msg++ {
+		assertions.Nil(<-errs)
+	}
+
+	broker.Close()
+}
+
+// isClosed reports whether a channel is closed.
+func isClosed(ch chan void) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,180 @@
+package broker
+
+import (
+	"fmt"
+	"time"
+)
+
+// Metric identifies a broker metric monitored by alerts.
+type Metric int
+
+const (
+	// DropRate is the ratio of dropped messages to all messages sent to clients within the window.
+	DropRate Metric = iota
+	// BufferOccupancy is the ratio of buffered messages to the buffer size at the end of the window.
+	BufferOccupancy
+	// BroadcastLatency is the average time in seconds spent broadcasting a message within the window.
+	BroadcastLatency
+)
+
+// String returns the name of the metric.
+func (metric Metric) String() string {
+	switch metric {
+	case DropRate:
+		return "drop rate"
+	case BufferOccupancy:
+		return "buffer occupancy"
+	case BroadcastLatency:
+		return "broadcast latency"
+	default:
+		return fmt.Sprintf("Metric(%d)", int(metric))
+	}
+}
+
+// Alert describes a threshold crossing of a metric, which is passed to the alert hook.
+type Alert struct {
+	// Name is the name of the broker.
+	Name string
+	// Metric is the metric that crossed its threshold.
+	Metric Metric
+	// Value is the value of the metric within the window.
+	Value float64
+	// Firing reports whether the value exceeded the fire threshold, or fell back to the resolve threshold.
+	Firing bool
+}
+
+// threshold holds the thresholds of a metric, and whether its alert is currently firing.
+type threshold struct {
+	metric  Metric
+	fire    float64
+	resolve float64
+	firing  bool
+}
+
+// Alerts evaluates the stats of a broker on every window and fires alerts when metrics cross their thresholds.
+type Alerts struct {
+	source     StatsSource
+	thresholds []threshold
+	hook       func(Alert)
+	previous   Stats
+	stop       chan void
+	done       chan void
+}
+
+// AlertBuilder encapsulates the construction of new alerts.
+type AlertBuilder struct {
+	window     time.Duration
+	thresholds []threshold
+	hook       func(Alert)
+}
+
+// defaultAlertWindow specifies the default window in which the metrics are evaluated.
+const defaultAlertWindow = 10 * time.Second
+
+// NewAlertBuilder constructs a new alert builder.
+func NewAlertBuilder() AlertBuilder {
+	return AlertBuilder{window: defaultAlertWindow}
+}
+
+// Window configures the window in which the metrics are evaluated.
+func (builder AlertBuilder) Window(window time.Duration) AlertBuilder {
+	builder.window = window
+	return builder
+}
+
+// Threshold configures an alert that fires when the metric exceeds the fire threshold,
+// and is resolved when the metric falls back to the resolve threshold, which is at most the fire threshold.
+// The gap between the thresholds prevents an alert from flapping when the metric oscillates around a threshold.
+func (builder AlertBuilder) Threshold(metric Metric, fire, resolve float64) AlertBuilder {
+	builder.thresholds = append(builder.thresholds, threshold{metric: metric, fire: fire, resolve: resolve})
+	return builder
+}
+
+// OnAlert configures the hook that is called when an alert fires or is resolved.
+func (builder AlertBuilder) OnAlert(hook func(Alert)) AlertBuilder {
+	builder.hook = hook
+	return builder
+}
+
+// Build builds new alerts that evaluate the stats of the source on every window.
+func (builder AlertBuilder) Build(source StatsSource) *Alerts {
+	alerts := &Alerts{
+		source:     source,
+		thresholds: append([]threshold(nil), builder.thresholds...),
+		hook:       builder.hook,
+		previous:   source.Stats(),
+		stop:       make(chan void),
+		done:       make(chan void),
+	}
+	go alerts.run(builder.window)
+	return alerts
+}
+
+// Close stops evaluating the alerts.
+// Panics when the alerts are already stopped.
+func (alerts *Alerts) Close() {
+	close(alerts.stop)
+	<-alerts.done
+}
+
+// run starts the alerts loop.
+func (alerts *Alerts) run(window time.Duration) {
+	defer close(alerts.done)
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-alerts.stop:
+			return
+		case <-ticker.C:
+			alerts.evaluate()
+		}
+	}
+}
+
+// evaluate computes the metrics of the window since the previous evaluation, and fires or resolves alerts.
+func (alerts *Alerts) evaluate() {
+	stats := alerts.source.Stats()
+	previous := alerts.previous
+	alerts.previous = stats
+	// start from zero if the counters were reset since the previous evaluation
+	if stats.ResetSince(previous) {
+		previous = Stats{}
+	}
+
+	for i := range alerts.thresholds {
+		threshold := &alerts.thresholds[i]
+		value := metricValue(threshold.metric, stats, previous)
+		switch {
+		case !threshold.firing && value > threshold.fire:
+			threshold.firing = true
+		case threshold.firing && value <= threshold.resolve:
+			threshold.firing = false
+		default:
+			continue
+		}
+		if alerts.hook != nil {
+			alerts.hook(Alert{Name: stats.Name, Metric: threshold.metric, Value: value, Firing: threshold.firing})
+		}
+	}
+}
+
+// metricValue computes the value of a metric within the window between the previous and the current stats.
+func metricValue(metric Metric, stats, previous Stats) float64 {
+	switch metric {
+	case DropRate:
+		dropped := stats.Dropped - previous.Dropped
+		if sent := dropped + stats.Delivered - previous.Delivered; sent > 0 {
+			return float64(dropped) / float64(sent)
+		}
+	case BufferOccupancy:
+		if stats.BufferSize > 0 {
+			return float64(stats.Buffered) / float64(stats.BufferSize)
+		}
+	case BroadcastLatency:
+		if broadcasts := stats.Broadcasts - previous.Broadcasts; broadcasts > 0 {
+			return (stats.BroadcastTime - previous.BroadcastTime).Seconds() / float64(broadcasts)
+		}
+	}
+	return 0
+}
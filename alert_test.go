@@ -0,0 +1,104 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// statsSequence is a stats source returning the next stats on every call.
+type statsSequence []Stats
+
+func (sequence *statsSequence) Stats() Stats {
+	stats := (*sequence)[0]
+	*sequence = (*sequence)[1:]
+	return stats
+}
+
+func TestAlertsEvaluate(t *testing.T) {
+	assertions := assert.New(t)
+
+	source := statsSequence{
+		{Name: "events", BufferSize: 10},
+		{Name: "events", Delivered: 5, Dropped: 5, Buffered: 9, BufferSize: 10, Broadcasts: 5, BroadcastTime: time.Second},
+		{Name: "events", Delivered: 12, Dropped: 8, Buffered: 6, BufferSize: 10, Broadcasts: 10, BroadcastTime: time.Second},
+		{Name: "events", Delivered: 22, Dropped: 8, Buffered: 2, BufferSize: 10, Broadcasts: 20, BroadcastTime: time.Second},
+	}
+	var fired []Alert
+	alerts := NewAlertBuilder().
+		Threshold(DropRate, 0.4, 0.1).
+		Threshold(BufferOccupancy, 0.8, 0.5).
+		Threshold(BroadcastLatency, 0.1, 0.01).
+		OnAlert(func(alert Alert) { fired = append(fired, alert) })
+	// evaluate the windows synchronously instead of running the alerts loop
+	evaluated := &Alerts{source: &source, thresholds: alerts.thresholds, hook: alerts.hook, previous: source.Stats()}
+
+	evaluated.evaluate()
+	assertions.Equal([]Alert{
+		{Name: "events", Metric: DropRate, Value: 0.5, Firing: true},
+		{Name: "events", Metric: BufferOccupancy, Value: 0.9, Firing: true},
+		{Name: "events", Metric: BroadcastLatency, Value: 0.2, Firing: true},
+	}, fired)
+
+	// the metrics are between the thresholds, so that the alerts keep firing
+	fired = nil
+	evaluated.evaluate()
+	assertions.Equal([]Alert{
+		{Name: "events", Metric: BroadcastLatency, Value: 0, Firing: false},
+	}, fired)
+
+	fired = nil
+	evaluated.evaluate()
+	assertions.Equal([]Alert{
+		{Name: "events", Metric: DropRate, Value: 0, Firing: false},
+		{Name: "events", Metric: BufferOccupancy, Value: 0.2, Firing: false},
+	}, fired)
+}
+
+func TestAlerts(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(10 * time.Millisecond).Build()
+	_, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	fired := make(chan Alert, 1)
+	alerts := NewAlertBuilder().
+		Window(50*time.Millisecond).
+		Threshold(DropRate, 0.5, 0).
+		OnAlert(func(alert Alert) { fired <- alert }).
+		Build(broker)
+
+	// the client never receives, so that all messages are dropped
+	assertions.Nil(broker.Publish(1))
+	assertions.Equal(Alert{Metric: DropRate, Value: 1, Firing: true}, <-fired)
+
+	alerts.Close()
+	broker.Close()
+}
+
+func TestMetricString(t *testing.T) {
+	assertions := assert.New(t)
+
+	assertions.Equal("drop rate", DropRate.String())
+	assertions.Equal("buffer occupancy", BufferOccupancy.String())
+	assertions.Equal("broadcast latency", BroadcastLatency.String())
+	assertions.Equal("Metric(42)", Metric(42).String())
+}
+
+func TestAlertsEvaluateReset(t *testing.T) {
+	assertions := assert.New(t)
+
+	// the counters were reset since the previous evaluation
+	source := statsSequence{{Delivered: 1, Dropped: 1}}
+	var fired []Alert
+	alerts := &Alerts{
+		source:     &source,
+		thresholds: []threshold{{metric: DropRate, fire: 0.4, resolve: 0.1}},
+		hook:       func(alert Alert) { fired = append(fired, alert) },
+		previous:   Stats{Delivered: 10, Dropped: 10},
+	}
+	alerts.evaluate()
+	assertions.Equal([]Alert{{Metric: DropRate, Value: 0.5, Firing: true}}, fired)
+}
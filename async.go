@@ -0,0 +1,107 @@
+package broker
+
+import (
+	"context"
+	"time"
+)
+
+// Outcome is the outcome of broadcasting a message published asynchronously.
+type Outcome struct {
+	// Delivered is the number of clients that received the message.
+	Delivered int
+	// Dropped is the number of clients that did not receive the message in time.
+	Dropped int
+	// Expired reports whether the message was discarded because its time to live elapsed.
+	Expired bool
+	// Suppressed reports whether the message was discarded because it equals the previously broadcast message,
+	// or duplicates a message published within the dedup window.
+	Suppressed bool
+	// Err is the error if the message was not published or not broadcast,
+	// like ErrTimeout on timeout or ErrClosed if the broker was closed before.
+	Err error
+}
+
+// PublishAsync publishes a message with per-message options to the broker without blocking,
+// and returns a channel receiving the outcome once the message was broadcast or discarded.
+// Messages published asynchronously at the same time may be buffered in a different order than they were published.
+func (broker *Broker[T]) PublishAsync(message T, opts ...PublishOption) <-chan Outcome {
+	outcome := make(chan Outcome, 1)
+	if broker.readOnly {
+		outcome <- Outcome{Err: broker.error(ErrReadOnly)}
+		return outcome
+	}
+	options := publishOptions{timeout: broker.currentTimeout()}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	env := broker.wrap(message, options)
+	env.outcome = outcome
+	go func() {
+		if err := broker.send(context.Background(), &env, time.After(options.timeout)); err != nil {
+			outcome <- Outcome{Err: err}
+		}
+	}()
+	return outcome
+}
+
+// PublishCounted publishes a message with per-message options to the broker, and blocks until it was broadcast.
+// Returns how many clients received the message, and how many clients did not receive it in time.
+// Both are zero if the message expired or was suppressed.
+// Returns ErrTimeout on timeout, ErrClosed if the broker is closed, or ErrReadOnly if the broker is a mirror.
+func (broker *Broker[T]) PublishCounted(message T, opts ...PublishOption) (delivered, dropped int, err error) {
+	if broker.readOnly {
+		return 0, 0, broker.error(ErrReadOnly)
+	}
+	options := publishOptions{timeout: broker.currentTimeout()}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	env := broker.wrap(message, options)
+	outcome := make(chan Outcome, 1)
+	env.outcome = outcome
+	if err := broker.send(context.Background(), &env, time.After(options.timeout)); err != nil {
+		return 0, 0, err
+	}
+	select {
+	case result := <-outcome:
+		return result.Delivered, result.Dropped, result.Err
+	case <-broker.done:
+		// the outcome may be resolved by the broker loop before it terminated
+		select {
+		case result := <-outcome:
+			return result.Delivered, result.Dropped, result.Err
+		default:
+			return 0, 0, broker.error(ErrClosed)
+		}
+	}
+}
+
+// outcome returns the outcome of broadcasting the message from its delivery record.
+func (trace *Trace[T]) outcome(err error) Outcome {
+	outcome := Outcome{Expired: trace.Expired, Suppressed: trace.Suppressed, Err: err}
+	for _, delivery := range trace.Deliveries {
+		if delivery.Dropped {
+			outcome.Dropped++
+		} else {
+			outcome.Delivered++
+		}
+	}
+	return outcome
+}
+
+// discard resolves the outcome of all buffered, pending and scheduled messages published asynchronously
+// when the broker is stopped, as they are not broadcast anymore.
+func (broker *Broker[T]) discard() {
+	for _, buffer := range append(broker.retired, broker.buffer) {
+		for len(buffer.messages) > 0 {
+			broker.enqueue(<-buffer.messages)
+		}
+	}
+	for _, envelopes := range [][]envelope[T]{broker.pending.envelopes, broker.scheduled.envelopes} {
+		for _, env := range envelopes {
+			if env.outcome != nil {
+				env.outcome <- Outcome{Err: broker.error(ErrClosed)}
+			}
+		}
+	}
+}
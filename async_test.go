@@ -0,0 +1,87 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishAsync(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewDedupBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+	_, err = broker.Subscribe()
+	assertions.Nil(err)
+
+	go func() {
+		for range client {
+		}
+	}()
+	assertions.Equal(Outcome{Delivered: 1, Dropped: 1}, <-broker.PublishAsync(1))
+	assertions.Equal(Outcome{Suppressed: true}, <-broker.PublishAsync(1))
+
+	broker.Close()
+	outcome := <-broker.PublishAsync(2)
+	assertions.ErrorIs(outcome.Err, ErrClosed)
+}
+
+func TestPublishAsyncExpired(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(time.Minute).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// block the broker loop while it sends the first message
+	first := broker.PublishAsync(0)
+	time.Sleep(50 * time.Millisecond)
+
+	// messages published asynchronously at the same time may be buffered in any order
+	expired := broker.PublishAsync(1, WithTTL(time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+	discarded := broker.PublishAsync(2)
+	time.Sleep(10 * time.Millisecond)
+	pending := broker.PublishAsync(3)
+	time.Sleep(10 * time.Millisecond)
+
+	assertions.Equal(0, <-client)
+	assertions.Equal(Outcome{Delivered: 1}, <-first)
+	assertions.Equal(Outcome{Expired: true}, <-expired)
+
+	// the message is not broadcast to all clients when the broker is closed
+	broker.Close()
+	outcome := <-discarded
+	assertions.ErrorIs(outcome.Err, ErrClosed)
+	assertions.Equal(1, outcome.Dropped)
+	assertions.ErrorIs((<-pending).Err, ErrClosed)
+}
+
+func TestPublishCounted(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	delivered, dropped, err := broker.PublishCounted(1)
+	assertions.Zero(delivered)
+	assertions.Zero(dropped)
+	assertions.Nil(err)
+
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+	_, err = broker.Subscribe()
+	assertions.Nil(err)
+	go func() {
+		for range client {
+		}
+	}()
+	delivered, dropped, err = broker.PublishCounted(2)
+	assertions.Equal(1, delivered)
+	assertions.Equal(1, dropped)
+	assertions.Nil(err)
+
+	broker.Close()
+	_, _, err = broker.PublishCounted(3)
+	assertions.ErrorIs(err, ErrClosed)
+}
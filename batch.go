@@ -0,0 +1,45 @@
+package broker
+
+import "time"
+
+// Batch coalesces the messages received by a client within a window into a single delivery of at most maxSize messages.
+// The window starts with the first message of a batch, and the batch keeps growing until it is received.
+// A full batch is delivered immediately, and no more messages are received from the client until it is taken,
+// so that the client applies its backpressure to the broker. A maxSize below 1 is treated as 1.
+// The returned channel is closed after the last batch when the client is closed, e.g. after unsubscribing it.
+func Batch[T any](client <-chan T, window time.Duration, maxSize int) <-chan []T {
+	if maxSize < 1 {
+		maxSize = 1
+	}
+	batches := make(chan []T)
+	go func() {
+		defer close(batches)
+		var batch []T
+		var elapsed <-chan time.Time
+		var ready chan []T
+		for client != nil || len(batch) > 0 {
+			receive := client
+			if len(batch) >= maxSize {
+				// stop receiving until the full batch is taken
+				receive, elapsed, ready = nil, nil, batches
+			}
+			select {
+			case msg, ok := <-receive:
+				if !ok {
+					// flush the last batch immediately
+					client, elapsed, ready = nil, nil, batches
+					continue
+				}
+				if len(batch) == 0 {
+					elapsed = time.After(window)
+				}
+				batch = append(batch, msg)
+			case <-elapsed:
+				elapsed, ready = nil, batches
+			case ready <- batch:
+				batch, ready = nil, nil
+			}
+		}
+	}()
+	return batches
+}
@@ -0,0 +1,80 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+	batches := Batch(client, 50*time.Millisecond, 10)
+
+	for msg := 1; msg <= 3; msg++ {
+		assertions.Nil(broker.Publish(msg))
+	}
+	assertions.Equal([]int{1, 2, 3}, <-batches)
+
+	assertions.Nil(broker.Publish(4))
+	assertions.Equal([]int{4}, <-batches)
+
+	// the last batch is delivered when the client is closed
+	assertions.Nil(broker.Publish(5))
+	time.Sleep(10 * time.Millisecond)
+	assertions.Nil(broker.Unsubscribe(client))
+	assertions.Equal([]int{5}, <-batches)
+	_, ok := <-batches
+	assertions.False(ok)
+
+	broker.Close()
+}
+
+func TestBatchMaxSize(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().BufferSize(0).Timeout(100 * time.Millisecond).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+	batches := Batch(client, time.Minute, 2)
+
+	// a full batch is delivered without waiting for the window
+	assertions.Nil(broker.Publish(1))
+	assertions.Nil(broker.Publish(2))
+	assertions.Equal([]int{1, 2}, <-batches)
+
+	// no more messages are received until the full batch is taken, so the client drops the message on timeout
+	for msg := 3; msg <= 5; msg++ {
+		assertions.Nil(broker.Publish(msg))
+	}
+	time.Sleep(150 * time.Millisecond)
+	assertions.Equal([]int{3, 4}, <-batches)
+	assertions.Equal(uint64(1), broker.Stats().Dropped)
+
+	assertions.Nil(broker.Unsubscribe(client))
+	_, ok := <-batches
+	assertions.False(ok)
+	broker.Close()
+}
+
+func TestBatchSubscription(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+	subscription, err := NewSubscription(broker)
+	assertions.Nil(err)
+	batches := Batch(subscription.C(), 50*time.Millisecond, 10)
+
+	assertions.Nil(broker.Publish(1))
+	assertions.Equal([]int{1}, <-batches)
+
+	assertions.Nil(subscription.Unsubscribe())
+	_, ok := <-batches
+	assertions.False(ok)
+
+	broker.Close()
+}
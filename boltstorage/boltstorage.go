@@ -0,0 +1,242 @@
+// Package boltstorage provides a persistent broker storage backed by a bbolt database file,
+// so that the messages of a broker survive restarts of the process.
+package boltstorage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mpe85/go-broker"
+	"go.etcd.io/bbolt"
+)
+
+// Storage stores the messages of a broker in a bbolt database file. It implements broker.Storage.
+type Storage[T any] struct {
+	mutex       sync.RWMutex
+	db          *bbolt.DB
+	path        string
+	bucket      []byte
+	maxMessages uint64
+	marshal     func(T) ([]byte, error)
+	unmarshal   func([]byte, *T) error
+}
+
+// Storage implements broker.Storage.
+var _ broker.Storage[int] = (*Storage[int])(nil)
+
+// Builder encapsulates the construction of a new storage.
+type Builder[T any] struct {
+	bucket      string
+	maxMessages int
+	marshal     func(T) ([]byte, error)
+	unmarshal   func([]byte, *T) error
+}
+
+// defaultBucket specifies the default name of the bucket storing the messages.
+const defaultBucket = "messages"
+
+// openTimeout specifies how long opening a database file waits for the lock held by another process.
+const openTimeout = time.Second
+
+// ErrClosed is the error returned when the storage was closed.
+var ErrClosed = errors.New("storage closed")
+
+// NewBuilder constructs a new builder, which encodes messages as JSON by default.
+func NewBuilder[T any]() Builder[T] {
+	return Builder[T]{
+		bucket:    defaultBucket,
+		marshal:   func(message T) ([]byte, error) { return json.Marshal(message) },
+		unmarshal: func(data []byte, message *T) error { return json.Unmarshal(data, message) },
+	}
+}
+
+// Bucket configures the name of the bucket storing the messages,
+// so that the messages of multiple brokers can be stored in the same database file.
+func (builder Builder[T]) Bucket(bucket string) Builder[T] {
+	builder.bucket = bucket
+	return builder
+}
+
+// MaxMessages configures the maximum number of stored messages.
+// When a message is appended to a full storage, the oldest message is removed. By default, the number is unlimited.
+func (builder Builder[T]) MaxMessages(maxMessages int) Builder[T] {
+	builder.maxMessages = maxMessages
+	return builder
+}
+
+// Codec configures the functions encoding and decoding the stored messages.
+func (builder Builder[T]) Codec(marshal func(T) ([]byte, error), unmarshal func([]byte, *T) error) Builder[T] {
+	builder.marshal, builder.unmarshal = marshal, unmarshal
+	return builder
+}
+
+// Build opens the database file at the path, which is created if it does not exist, and builds a new storage.
+func (builder Builder[T]) Build(path string) (*Storage[T], error) {
+	storage := &Storage[T]{
+		path:        path,
+		bucket:      []byte(builder.bucket),
+		maxMessages: uint64(builder.maxMessages),
+		marshal:     builder.marshal,
+		unmarshal:   builder.unmarshal,
+	}
+	if err := storage.open(); err != nil {
+		return nil, err
+	}
+	return storage, nil
+}
+
+// open opens the database file, and creates the bucket if it does not exist.
+func (storage *Storage[T]) open() error {
+	db, err := bbolt.Open(storage.path, 0o600, &bbolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return err
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(storage.bucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return err
+	}
+	storage.db = db
+	return nil
+}
+
+// Append stores a message, and returns its offset. The message is synced to disk before Append returns.
+func (storage *Storage[T]) Append(message T) (uint64, error) {
+	data, err := storage.marshal(message)
+	if err != nil {
+		return 0, err
+	}
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	if storage.db == nil {
+		return 0, ErrClosed
+	}
+	var offset uint64
+	err = storage.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(storage.bucket)
+		sequence, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		offset = sequence - 1
+		if err := bucket.Put(key(offset), data); err != nil {
+			return err
+		}
+		if storage.maxMessages == 0 {
+			return nil
+		}
+		// remove the oldest messages, as the offsets of the stored messages are contiguous
+		cursor := bucket.Cursor()
+		for k, _ := cursor.First(); k != nil && offset-binary.BigEndian.Uint64(k) >= storage.maxMessages; k, _ = cursor.Next() {
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return offset, err
+}
+
+// ReadFrom returns up to max stored messages, starting with the message at the offset,
+// or the oldest stored message if the message at the offset was removed already.
+// Returns the offset of the first returned message, or the offset itself if no message is returned.
+func (storage *Storage[T]) ReadFrom(offset uint64, max int) ([]T, uint64, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	if storage.db == nil {
+		return nil, offset, ErrClosed
+	}
+	var messages []T
+	first := offset
+	err := storage.db.View(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(storage.bucket).Cursor()
+		for k, v := cursor.Seek(key(offset)); k != nil && len(messages) < max; k, v = cursor.Next() {
+			if messages == nil {
+				first = binary.BigEndian.Uint64(k)
+			}
+			var message T
+			if err := storage.unmarshal(v, &message); err != nil {
+				return err
+			}
+			messages = append(messages, message)
+		}
+		return nil
+	})
+	return messages, first, err
+}
+
+// Trim removes all stored messages before the offset.
+func (storage *Storage[T]) Trim(offset uint64) error {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	if storage.db == nil {
+		return ErrClosed
+	}
+	return storage.db.Update(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(storage.bucket).Cursor()
+		for k, _ := cursor.First(); k != nil && binary.BigEndian.Uint64(k) < offset; k, _ = cursor.Next() {
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Compact rewrites the database file without the space of removed messages, which bbolt does not release otherwise.
+// The storage is blocked while compacting.
+func (storage *Storage[T]) Compact() error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	if storage.db == nil {
+		return ErrClosed
+	}
+	compacted := storage.path + ".compact"
+	dst, err := bbolt.Open(compacted, 0o600, &bbolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return err
+	}
+	if err := bbolt.Compact(dst, storage.db, 0); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(compacted)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		_ = os.Remove(compacted)
+		return err
+	}
+	if err := storage.db.Close(); err != nil {
+		return err
+	}
+	storage.db = nil
+	if err := os.Rename(compacted, storage.path); err != nil {
+		return err
+	}
+	return storage.open()
+}
+
+// Close closes the database file. Returns ErrClosed if the storage is already closed.
+func (storage *Storage[T]) Close() error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	if storage.db == nil {
+		return ErrClosed
+	}
+	err := storage.db.Close()
+	storage.db = nil
+	return err
+}
+
+// key returns the key of the message at the offset, which sorts by offset.
+func key(offset uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, offset)
+	return k
+}
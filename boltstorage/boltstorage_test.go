@@ -0,0 +1,124 @@
+package boltstorage
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/mpe85/go-broker"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func TestStorage(t *testing.T) {
+	assertions := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "broker.db")
+	storage, err := NewBuilder[string]().Build(path)
+	assertions.Nil(err)
+	for i := 0; i < 4; i++ {
+		offset, err := storage.Append(strconv.Itoa(i))
+		assertions.Nil(err)
+		assertions.Equal(uint64(i), offset)
+	}
+	messages, _, err := storage.ReadFrom(1, 2)
+	assertions.Nil(err)
+	assertions.Equal([]string{"1", "2"}, messages)
+	messages, first, err := storage.ReadFrom(1, -1)
+	assertions.Nil(err)
+	assertions.Empty(messages)
+	assertions.Equal(uint64(1), first)
+
+	assertions.Nil(storage.Trim(2))
+	messages, first, err = storage.ReadFrom(0, 10)
+	assertions.Nil(err)
+	assertions.Equal([]string{"2", "3"}, messages)
+	assertions.Equal(uint64(2), first)
+	messages, first, err = storage.ReadFrom(4, 10)
+	assertions.Nil(err)
+	assertions.Empty(messages)
+	assertions.Equal(uint64(4), first)
+	assertions.Nil(storage.Close())
+	assertions.ErrorIs(storage.Close(), ErrClosed)
+	_, err = storage.Append("4")
+	assertions.ErrorIs(err, ErrClosed)
+
+	// the messages survive reopening the storage, and the offsets continue
+	storage, err = NewBuilder[string]().Build(path)
+	assertions.Nil(err)
+	offset, err := storage.Append("4")
+	assertions.Nil(err)
+	assertions.Equal(uint64(4), offset)
+	messages, _, err = storage.ReadFrom(0, 10)
+	assertions.Nil(err)
+	assertions.Equal([]string{"2", "3", "4"}, messages)
+	assertions.Nil(storage.Close())
+}
+
+func TestStorageMaxMessages(t *testing.T) {
+	assertions := assert.New(t)
+
+	storage, err := NewBuilder[int]().Bucket("numbers").MaxMessages(2).Build(filepath.Join(t.TempDir(), "broker.db"))
+	assertions.Nil(err)
+	for i := 0; i < 5; i++ {
+		_, err := storage.Append(i)
+		assertions.Nil(err)
+	}
+	messages, _, err := storage.ReadFrom(0, 10)
+	assertions.Nil(err)
+	assertions.Equal([]int{3, 4}, messages)
+	assertions.Nil(storage.Close())
+}
+
+func TestStorageCompact(t *testing.T) {
+	assertions := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "broker.db")
+	storage, err := NewBuilder[[]byte]().Build(path)
+	assertions.Nil(err)
+	for i := 0; i < 100; i++ {
+		_, err := storage.Append(make([]byte, 10000))
+		assertions.Nil(err)
+	}
+	assertions.Nil(storage.Trim(99))
+	before, err := os.Stat(path)
+	assertions.Nil(err)
+
+	assertions.Nil(storage.Compact())
+	after, err := os.Stat(path)
+	assertions.Nil(err)
+	assertions.Less(after.Size(), before.Size())
+	messages, _, err := storage.ReadFrom(0, 10)
+	assertions.Nil(err)
+	assertions.Len(messages, 1)
+	assertions.Nil(storage.Close())
+	assertions.ErrorIs(storage.Compact(), ErrClosed)
+}
+
+func TestBroker(t *testing.T) {
+	assertions := assert.New(t)
+
+	storage, err := NewBuilder[string]().Build(filepath.Join(t.TempDir(), "broker.db"))
+	assertions.Nil(err)
+	theBroker := broker.NewBuilder[string]().Storage(storage).Build()
+	assertions.Nil(theBroker.PublishSync("Hello"))
+	messages, _, err := storage.ReadFrom(0, 10)
+	assertions.Nil(err)
+	assertions.Equal([]string{"Hello"}, messages)
+
+	theBroker.Close()
+	assertions.Nil(storage.Close())
+}
+
+func TestBuildInvalidPath(t *testing.T) {
+	assertions := assert.New(t)
+
+	storage, err := NewBuilder[string]().Build(filepath.Join(t.TempDir(), "missing", "broker.db"))
+	assertions.Nil(storage)
+	assertions.Error(err)
+}
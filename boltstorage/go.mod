@@ -0,0 +1,17 @@
+module github.com/mpe85/go-broker/boltstorage
+
+go 1.21
+
+require (
+	github.com/mpe85/go-broker v0.0.0-00010101000000-000000000000
+	github.com/stretchr/testify v1.10.0
+	go.etcd.io/bbolt v1.3.10
+	go.uber.org/goleak v1.3.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -2,7 +2,12 @@
 package broker
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -10,22 +15,131 @@ import (
 type Client[T any] chan T
 
 // void represents an empty struct that consumes no memory.
-type void struct{}
+type void = struct{}
+
+// subscriber holds the state of a client registered to the broker.
+type subscriber[T any] struct {
+	filter       func(T) bool
+	routes       map[RouteKey]void
+	credited     bool
+	credits      int
+	catchUp      bool
+	replay       int
+	overflow     Overflow
+	group        string
+	seen         uint64
+	misses       int
+	delivered    uint64
+	dropped      uint64
+	lastDelivery time.Time
+	lagging      bool
+}
+
+// registration carries a new client with its initial state to the broker loop.
+type registration[T any] struct {
+	client     Client[T]
+	subscriber *subscriber[T]
+}
+
+// unsubscription carries a client to remove to the broker loop, which closes done after it removed the client.
+type unsubscription[T any] struct {
+	client Client[T]
+	done   chan void
+}
+
+// filterUpdate carries a filter replacement for a client to the broker loop.
+type filterUpdate[T any] struct {
+	client Client[T]
+	filter func(T) bool
+}
 
 // Broker broadcasts messages to registered clients
 type Broker[T any] struct {
-	clients              map[Client[T]]void
+	name                 string
+	clients              map[Client[T]]*subscriber[T]
+	groups               map[string]*group[T]
+	roundRobin           bool
+	conflating           bool
 	stop                 chan void
-	subscribingClients   chan Client[T]
-	unsubscribingClients chan Client[T]
-	messages             chan T
-	timeout              time.Duration
+	done                 chan void
+	shutdown             chan void
+	drained              chan void
+	closed               atomic.Bool
+	closedBySource       atomic.Bool
+	reason               error
+	subscribingClients   chan registration[T]
+	unsubscribingClients chan unsubscription[T]
+	filterUpdates        chan filterUpdate[T]
+	statusRequests       chan statusRequest[T]
+	grants               chan grant[T]
+	resizes              chan *messageBuffer[T]
+	admission            *admission
+	messages             atomic.Pointer[messageBuffer[T]]
+	buffer               *messageBuffer[T]
+	retired              []*messageBuffer[T]
+	pending              pendingQueue[T]
+	scheduled            scheduledQueue[T]
+	maxScheduled         int64
+	scheduling           atomic.Int64
+	configMutex          sync.Mutex
+	timeout              atomic.Int64
+	equal                func(T, T) bool
+	route                func(T) []RouteKey
+	dedup                *dedup[T]
+	sizer                func(T) int
+	tracer               func(Trace[T])
+	enrichers            []func(T, map[string]string)
+	middleware           []Middleware[T]
+	sweepInterval        time.Duration
+	evictAfter           int
+	evictHook            func(Client[T])
+	evicted              map[Client[T]]*SubscriberStatus
+	history              *history[T]
+	retain               bool
+	storage              Storage[T]
+	wal                  *WAL[T]
+	replayAfter          int
+	holding              bool
+	statsBroker          *Broker[Stats]
+	requestsMutex        sync.Mutex
+	requests             *Broker[*request]
+	readOnly             bool
+	topic                atomic.Pointer[topicHost]
+	last                 T
+	hasLast              bool
+	broadcasting         *envelope[T]
+	joined               []Client[T]
+	counters             counters
+	rates                rates
 }
 
 // Builder encapsulates the construction of a new broker.
 type Builder[T any] struct {
-	timeout    time.Duration
-	bufferSize int
+	name        string
+	timeout     time.Duration
+	bufferSize  int
+	subscribers int
+	fair        bool
+	equal       func(T, T) bool
+	route       func(T) []RouteKey
+	dedupWindow time.Duration
+	identify    func(T) (any, bool)
+	sizer       func(T) int
+	tracer      func(Trace[T])
+	enrichers   []func(T, map[string]string)
+	middleware  []Middleware[T]
+	sweep       time.Duration
+	stats       time.Duration
+	evictAfter  int
+	evictHook   func(Client[T])
+	history     int
+	retain      bool
+	storage     Storage[T]
+	wal         *WAL[T]
+	replayAfter int
+	roundRobin  bool
+	conflate    bool
+	scheduled   int
 }
 
 // defaultTimeout specifies the default timeout when the broker tries to send a message to a client,
@@ -35,91 +149,641 @@ const defaultTimeout = time.Second
 // defaultBufferSize specifies the default size of the message buffer.
 const defaultBufferSize int = 10
 
+// defaultMaxScheduled specifies the default maximum number of messages scheduled for later.
+const defaultMaxScheduled int = 1000
+
 // ErrTimeout is the error returned when a broker operation timed out.
 var ErrTimeout = errors.New("timeout")
 
+// ErrClosed is the error returned when the broker was closed.
+var ErrClosed = errors.New("broker closed")
+
+// ready is an always closed channel, used to signal that there are pending messages in the broker loop.
+var ready = func() chan void {
+	ch := make(chan void)
+	close(ch)
+	return ch
+}()
+
 // Publish publishes a message to the broker.
-// Returns ErrTimeout on timeout.
+// Returns ErrTimeout on timeout, ErrClosed if the broker is closed, or ErrReadOnly if the broker is a mirror.
 func (broker *Broker[T]) Publish(message T) error {
+	return broker.PublishWithOptions(message)
+}
+
+// Subscribe registers a new client to the broker and returns it to the caller.
+// The client is also the handle to unsubscribe it, NewSubscription returns a handle exposing only the receiving end.
+// Returns ErrTimeout on timeout, ErrClosed if the broker is closed,
+// or ErrFilterType if the filter passed by WithFilter does not accept the message type of the broker.
+func (broker *Broker[T]) Subscribe(opts ...SubscribeOption) (Client[T], error) {
+	return broker.SubscribeContext(context.Background(), opts...)
+}
+
+// SubscribeContext registers a new client to the broker and returns it to the caller.
+// Instead of the broker timeout, it waits until the context is done, unless the context can never be done.
+// Returns the context error if the context is done, ErrTimeout on timeout, ErrClosed if the broker is closed,
+// or ErrFilterType if the filter passed by WithFilter does not accept the message type of the broker.
+func (broker *Broker[T]) SubscribeContext(ctx context.Context, opts ...SubscribeOption) (Client[T], error) {
+	var options subscribeOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if broker.retain && options.replay == 0 {
+		// replay the retained message
+		options.replay = 1
+	}
+	sub := &subscriber[T]{
+		catchUp:  options.catchUp,
+		replay:   options.replay,
+		overflow: options.overflow,
+		group:    options.group,
+		routes:   routeKeys(options.routes),
+	}
+	if options.filter != nil {
+		filter, ok := options.filter.(func(T) bool)
+		if !ok {
+			return nil, broker.error(fmt.Errorf("%w: %T does not accept %v", ErrFilterType, options.filter, typeOf[T]()))
+		}
+		sub.filter = filter
+	}
+	client := make(Client[T], options.bufferSize+options.replay)
+	if err := broker.register(ctx, client, sub); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// register adds a client with its initial state to the broker.
+// Returns the context error if the context is done, ErrTimeout on timeout, or ErrClosed if the broker is closed.
+func (broker *Broker[T]) register(ctx context.Context, client Client[T], sub *subscriber[T]) error {
 	select {
-	case broker.messages <- message:
+	case broker.subscribingClients <- registration[T]{client, sub}:
 		return nil
-	case <-time.After(broker.timeout):
-		return ErrTimeout
+	case <-broker.deadline(ctx, broker.currentTimeout()):
+		return broker.error(ErrTimeout)
+	case <-broker.stop:
+		return broker.error(ErrClosed)
+	case <-ctx.Done():
+		return broker.contextError(ctx.Err())
 	}
 }
 
-// Subscribe registers a new client to the broker and returns it to the caller.
-// Returns ErrTimeout on timeout.
-func (broker *Broker[T]) Subscribe() (Client[T], error) {
-	client := make(Client[T])
+// Unsubscribe removes a client from the broker, and blocks until the broker removed and closed it.
+// No further messages are sent to the client afterwards, but messages already buffered by it can still be received.
+// Returns ErrTimeout on timeout, or ErrClosed if the broker is closed.
+func (broker *Broker[T]) Unsubscribe(client Client[T]) error {
+	return broker.UnsubscribeContext(context.Background(), client)
+}
+
+// UnsubscribeContext removes a client from the broker like Unsubscribe.
+// Instead of the broker timeout, it waits until the context is done, unless the context can never be done.
+// Returns the context error if the context is done, ErrTimeout on timeout, or ErrClosed if the broker is closed.
+func (broker *Broker[T]) UnsubscribeContext(ctx context.Context, client Client[T]) error {
+	unsubscription := unsubscription[T]{client, make(chan void)}
 	select {
-	case broker.subscribingClients <- client:
-		return client, nil
-	case <-time.After(broker.timeout):
-		return nil, ErrTimeout
+	case broker.unsubscribingClients <- unsubscription:
+		<-unsubscription.done
+		return nil
+	case <-broker.deadline(ctx, broker.currentTimeout()):
+		return broker.error(ErrTimeout)
+	case <-broker.stop:
+		return broker.error(ErrClosed)
+	case <-ctx.Done():
+		return broker.contextError(ctx.Err())
 	}
 }
 
-// Unsubscribe removes a client from the broker.
-// Returns ErrTimeout on timeout.
-func (broker *Broker[T]) Unsubscribe(client Client[T]) error {
+// SetFilter atomically replaces the filter of a client, so that only messages matching the filter are sent to it.
+// A nil filter removes the filter. Has no effect if the client is not subscribed to the broker.
+// Returns ErrTimeout on timeout, or ErrClosed if the broker is closed.
+func (broker *Broker[T]) SetFilter(client Client[T], filter func(T) bool) error {
 	select {
-	case broker.unsubscribingClients <- client:
+	case broker.filterUpdates <- filterUpdate[T]{client, filter}:
 		return nil
-	case <-time.After(broker.timeout):
-		return ErrTimeout
+	case <-time.After(broker.currentTimeout()):
+		return broker.error(ErrTimeout)
+	case <-broker.stop:
+		return broker.error(ErrClosed)
 	}
 }
 
 // Close stops the broker and removes all leftover clients from it.
-// Panics when the broker is already stopped.
+// Panics when the broker is already stopped, unless it is a derived broker (like a mirror) stopped by its source.
 func (broker *Broker[T]) Close() {
+	if !broker.close(ErrClosed) && !broker.closedBySource.Load() {
+		panic("broker already closed")
+	}
+}
+
+// CloseWithContext gracefully stops the broker: it stops accepting published messages immediately,
+// but broadcasts all buffered messages to the clients before it removes them, until the context is done.
+// Messages scheduled for later are not waited for, but dropped (failing their asynchronous outcome with ErrClosed)
+// unless they are due before the buffered messages were broadcast. Messages held for the replay of a write-ahead log
+// are not broadcast, but replayed on the next startup.
+// Returns the context error if the context is done before all buffered messages were broadcast,
+// which are discarded then. Panics when the broker is already stopped,
+// unless it is a derived broker (like a mirror) stopped by its source.
+func (broker *Broker[T]) CloseWithContext(ctx context.Context) error {
+	if !broker.closed.CompareAndSwap(false, true) {
+		if broker.closedBySource.Load() {
+			return nil
+		}
+		panic("broker already closed")
+	}
+	close(broker.shutdown)
+	var err error
+	select {
+	case <-broker.drained:
+	case <-ctx.Done():
+		err = broker.contextError(ctx.Err())
+	}
+	broker.reason = ErrClosed
+	close(broker.stop)
+	<-broker.done
+	return err
+}
+
+// Run blocks until the broker is stopped, and returns the reason why it stopped:
+// ErrClosed if the broker was closed, or the cause of the context if the context is done (which closes the broker).
+// This allows to supervise the broker alongside other long-running components, like in an errgroup.Group.
+func (broker *Broker[T]) Run(ctx context.Context) error {
+	select {
+	case <-broker.done:
+	case <-ctx.Done():
+		broker.close(context.Cause(ctx))
+		<-broker.done
+	}
+	return broker.error(broker.reason)
+}
+
+// CloseReason returns the reason why the broker stopped, or nil if it is not stopped:
+// ErrClosed if the broker was closed, or the cause of the context passed to Run if the context is done.
+func (broker *Broker[T]) CloseReason() error {
+	select {
+	case <-broker.stop:
+		return broker.error(broker.reason)
+	default:
+		return nil
+	}
+}
+
+// IsClosed reports whether the broker was stopped, or is shutting down gracefully.
+// Publishing to a closed broker fails with ErrClosed.
+func (broker *Broker[T]) IsClosed() bool {
+	return broker.closed.Load()
+}
+
+// Done returns a channel that is closed when the broker loop terminated after the broker was stopped,
+// which happens after all clients still subscribed were closed.
+func (broker *Broker[T]) Done() <-chan struct{} {
+	return broker.done
+}
+
+// WaitClosed blocks until the broker loop terminated after the broker was stopped, and all clients were closed.
+// Returns the context error if the context is done before.
+func (broker *Broker[T]) WaitClosed(ctx context.Context) error {
+	select {
+	case <-broker.done:
+		return nil
+	case <-ctx.Done():
+		return broker.contextError(ctx.Err())
+	}
+}
+
+// String returns the name of the broker, which is used in errors, stats and goroutine labels.
+func (broker *Broker[T]) String() string {
+	if broker.name == "" {
+		return "broker"
+	}
+	return fmt.Sprintf("broker %q", broker.name)
+}
+
+// error wraps an error returned by the broker with its name, if the broker is named.
+func (broker *Broker[T]) error(err error) error {
+	if broker.name == "" || err == nil {
+		return err
+	}
+	return fmt.Errorf("%v: %w", broker, err)
+}
+
+// contextError wraps the error of a done context with the broker, even if the broker is not named,
+// so that it tells which broker gave up waiting. The context error can still be matched with errors.Is.
+func (broker *Broker[T]) contextError(err error) error {
+	return fmt.Errorf("%v: %w", broker, err)
+}
+
+// deadline returns a channel receiving after the timeout, or nil if the context can be done,
+// so that operations with a context wait until the context is done instead of the timeout.
+func (broker *Broker[T]) deadline(ctx context.Context, timeout time.Duration) <-chan time.Time {
+	if ctx.Done() != nil {
+		return nil
+	}
+	return time.After(timeout)
+}
+
+// currentTimeout returns the current broker timeout.
+func (broker *Broker[T]) currentTimeout() time.Duration {
+	return time.Duration(broker.timeout.Load())
+}
+
+// close stops the broker for a reason. Returns false if the broker is already stopped.
+func (broker *Broker[T]) close(reason error) bool {
+	if !broker.closed.CompareAndSwap(false, true) {
+		return false
+	}
+	broker.reason = reason
 	close(broker.stop)
+	return true
 }
 
 // run starts the broker loop.
 func (broker *Broker[T]) run() {
+	defer close(broker.done)
+	var sweep <-chan time.Time
+	if broker.sweepInterval > 0 {
+		ticker := time.NewTicker(broker.sweepInterval)
+		defer ticker.Stop()
+		sweep = ticker.C
+	}
+	rateTicker := time.NewTicker(rateInterval)
+	defer rateTicker.Stop()
+	defer broker.scheduled.stop()
+	broker.updateRates(time.Now())
+	shutdown, draining := broker.shutdown, false
 	for {
+		retired := broker.retire()
+		if draining && (broker.holding || len(broker.buffer.messages) == 0 && retired == nil && broker.pending.Len() == 0) {
+			// all buffered messages were broadcast after a graceful shutdown, or are held for replay,
+			// and stay in the write-ahead log until the next startup. Scheduled messages that are not due are dropped
+			close(broker.drained)
+			draining = false
+		}
+		// either receive a published message, or broadcast a pending message
+		messages, pending := broker.buffer.messages, chan void(nil)
+		if broker.holding {
+			// neither receive nor broadcast messages before the recovered messages are released
+			messages, retired = nil, nil
+		} else if broker.pending.Len() > 0 {
+			messages, retired, pending = nil, nil, ready
+		}
+		due := broker.scheduled.wait()
 		select {
 		case <-broker.stop:
 			// close all leftover clients and break the broker loop
+			broker.discard()
 			for client := range broker.clients {
+				broker.untrackClient(client)
 				close(client)
 			}
 			return
-		case client := <-broker.subscribingClients:
-			// add new client
-			broker.clients[client] = void{}
-		case client := <-broker.unsubscribingClients:
-			// remove and close client
-			delete(broker.clients, client)
-			close(client)
-		case msg := <-broker.messages:
-			// broadcast published message to all clients
-			for client := range broker.clients {
-				// send message to client (or discard message after timeout)
-				select {
-				case client <- msg:
-				case <-time.After(broker.timeout):
-				}
+		case <-shutdown:
+			shutdown, draining = nil, true
+		case registration := <-broker.subscribingClients:
+			broker.subscribe(registration)
+		case unsubscription := <-broker.unsubscribingClients:
+			broker.unsubscribe(unsubscription)
+		case resized := <-broker.resizes:
+			broker.resize(resized)
+		case update := <-broker.filterUpdates:
+			broker.updateFilter(update)
+		case <-rateTicker.C:
+			// update the moving averages of the counters
+			broker.updateRates(time.Now())
+		case grant := <-broker.grants:
+			broker.grant(grant)
+		case request := <-broker.statusRequests:
+			broker.reportStatus(request)
+		case env := <-messages:
+			// add published message to the pending messages, or hold it until it is due
+			broker.enqueue(env)
+		case env := <-retired:
+			// add a message sent to a replaced buffer by a publisher that loaded it before the resize
+			broker.enqueue(env)
+		case now := <-due:
+			broker.scheduled.fired()
+			broker.releaseDue(now)
+		case <-pending:
+			// take all buffered messages, so that the pending message with the highest priority is broadcast
+			broker.drain()
+			// discard expired messages if a sweep is due, so that they do not wait for the broadcasts before them
+			select {
+			case <-sweep:
+				broker.sweep(time.Now())
+			default:
+			}
+			if broker.pending.Len() > 0 {
+				broker.dispatch(broker.pending.pop())
 			}
 		}
 	}
 }
 
+// drain moves buffered messages to the pending (or scheduled) messages, until the pending messages fill the buffer size.
+// Messages sent to retired buffers are taken first, as they were published before the buffer was resized.
+func (broker *Broker[T]) drain() {
+	for _, buffer := range broker.retired {
+		broker.drainFrom(buffer.messages)
+	}
+	broker.drainFrom(broker.buffer.messages)
+}
+
+// drainFrom moves messages of a buffer to the pending (or scheduled) messages, until the pending messages fill
+// the buffer size.
+func (broker *Broker[T]) drainFrom(messages chan envelope[T]) {
+	for broker.pending.Len() < cap(broker.buffer.messages) {
+		select {
+		case env := <-messages:
+			broker.enqueue(env)
+		default:
+			return
+		}
+	}
+}
+
+// dispatch broadcasts a pending message, unless it expired, equals the previously broadcast message,
+// or duplicates a message published within the dedup window.
+// If a trace hook is configured, the delivery record of the message is passed to it afterwards.
+// If the message was published synchronously, the result of the broadcast is passed to the publisher.
+// If a storage is configured, the message is stored before it is broadcast, and discarded if it cannot be stored.
+// If a write-ahead log is configured, the message is acknowledged in it afterwards.
+func (broker *Broker[T]) dispatch(env envelope[T]) {
+	broker.untrack(&env)
+	defer broker.acknowledge(&env)
+	var err error
+	if env.result != nil {
+		defer func() { env.result <- err }()
+	}
+	if env.expired(time.Now()) {
+		broker.expire(&env)
+		return
+	}
+	var trace *Trace[T]
+	if broker.tracer != nil || env.outcome != nil {
+		trace = env.trace()
+		defer func() {
+			if broker.tracer != nil {
+				broker.tracer(*trace)
+			}
+			if env.outcome != nil {
+				env.outcome <- trace.outcome(err)
+			}
+		}()
+	}
+	if env.ctx != nil && env.ctx.Err() != nil {
+		// the synchronous publisher gave up already
+		err = env.ctx.Err()
+		return
+	}
+	if broker.equal != nil {
+		if broker.hasLast && broker.equal(broker.last, env.message) {
+			if trace != nil {
+				trace.Suppressed = true
+			}
+			return
+		}
+		broker.last, broker.hasLast = env.message, true
+	}
+	if broker.dedup != nil && broker.dedup.duplicate(&env) {
+		if trace != nil {
+			trace.Suppressed = true
+		}
+		return
+	}
+	if err = broker.store(&env); err != nil {
+		return
+	}
+	err = broker.broadcast(&env, trace)
+	if broker.history != nil {
+		broker.remember(&env)
+	}
+}
+
+// broadcast sends a published message to all clients (but only one client per group), and records the deliveries in the trace (if not nil).
+// If the message has a context, the broker waits for each client until the context is done instead of the timeout.
+// Returns ErrClosed if the broker was stopped during the broadcast,
+// or the context error if not all clients received the message before the context was done,
+// or ErrNotDelivered if any client dropped the message nonetheless.
+func (broker *Broker[T]) broadcast(env *envelope[T], trace *Trace[T]) error {
+	start := time.Now()
+	broker.routeOf(env)
+	broker.broadcasting = env
+	for client, sub := range broker.clients {
+		if !broker.grouped(sub) {
+			broker.sendTo(client, sub, env, trace)
+		}
+	}
+	broker.sendToGroups(env, trace)
+	// send message to clients subscribed with catch up during the broadcast, unless they were sent it already
+	for len(broker.joined) > 0 {
+		client := broker.joined[0]
+		broker.joined = broker.joined[1:]
+		if sub, ok := broker.clients[client]; ok && !broker.grouped(sub) && sub.seen != env.sequence {
+			broker.sendTo(client, sub, env, trace)
+		}
+	}
+	broker.broadcasting, broker.joined = nil, nil
+	broker.publishToPatterns(env)
+	broker.counters.broadcasts.Add(1)
+	broker.counters.broadcastTime.Add(int64(time.Since(start)))
+	select {
+	case <-broker.stop:
+		return ErrClosed
+	default:
+	}
+	if env.ctx == nil {
+		return nil
+	}
+	if err := env.ctx.Err(); err != nil {
+		return err
+	}
+	if env.dropped {
+		return ErrNotDelivered
+	}
+	return nil
+}
+
+// sendTo sends a message to a client, unless it does not match the filter or the route keys of the client,
+// and records the delivery in the trace (if not nil).
+func (broker *Broker[T]) sendTo(client Client[T], sub *subscriber[T], env *envelope[T], trace *Trace[T]) {
+	sub.seen = env.sequence
+	// skip client if message does not match its filter or route keys
+	if !sub.accepts(env) {
+		return
+	}
+	broker.transmit(client, sub, env, trace)
+}
+
+// transmit delivers a message to a client regardless of its filter and route keys,
+// records the delivery in the trace (if not nil), and evicts the client if it missed too many messages.
+func (broker *Broker[T]) transmit(client Client[T], sub *subscriber[T], env *envelope[T], trace *Trace[T]) {
+	delivered := broker.deliver(client, sub, env)
+	if _, ok := broker.clients[client]; !ok {
+		// the client unsubscribed while the broker was waiting for it
+		return
+	}
+	if delivered {
+		broker.counters.delivered.Add(1)
+		sub.delivered++
+		sub.lastDelivery = time.Now()
+		sub.lagging = false
+		sub.misses = 0
+		if env.quorum != nil {
+			env.quorum.receive(client)
+		}
+	} else {
+		broker.counters.dropped.Add(1)
+		env.dropped = true
+		sub.dropped++
+		sub.lagging = true
+		// messages discarded for lack of credits are not missed by the client
+		if !sub.credited || sub.credits > 0 {
+			sub.misses++
+		}
+	}
+	if trace != nil {
+		trace.Deliveries = append(trace.Deliveries, Delivery[T]{Client: client, At: time.Now(), Dropped: sub.lagging})
+	}
+	broker.observe(client, env, delivered)
+	if broker.evictAfter > 0 && sub.misses >= broker.evictAfter {
+		broker.evict(client)
+	}
+}
+
+// evict unsubscribes and closes a client that missed too many consecutive messages, and notifies the evict hook.
+// The status of the client is kept until it is requested.
+func (broker *Broker[T]) evict(client Client[T]) {
+	status := broker.clients[client].status(client)
+	status.State = Evicted
+	if broker.evicted == nil {
+		broker.evicted = make(map[Client[T]]*SubscriberStatus)
+	}
+	broker.evicted[client] = status
+	broker.unsubscribe(unsubscription[T]{client, make(chan void)})
+	if broker.evictHook != nil {
+		broker.evictHook(client)
+	}
+}
+
+// deliver sends a message to a client, and reports whether the client received it.
+// The message is discarded immediately if the client has no credits left.
+// Unless the overflow policy of the client is Block, the broker does not wait for the client.
+// While waiting for the client, the broker keeps serving control requests, so that they are not delayed by slow clients.
+func (broker *Broker[T]) deliver(client Client[T], sub *subscriber[T], env *envelope[T]) bool {
+	if sub.credited {
+		if sub.credits == 0 {
+			return false
+		}
+		sub.credits--
+	}
+	if sub.overflow != Block {
+		if broker.overflow(client, sub, env) {
+			return true
+		}
+		if sub.credited {
+			sub.credits++
+		}
+		return false
+	}
+	var timeout <-chan time.Time
+	var cancel <-chan struct{}
+	if env.ctx == nil {
+		timeout = time.After(broker.currentTimeout())
+	} else {
+		cancel = env.ctx.Done()
+	}
+	// send message to client (or discard message after timeout, or when the broker is stopped)
+send:
+	for {
+		select {
+		case client <- env.message:
+			return true
+		case <-timeout:
+			break send
+		case <-cancel:
+			break send
+		case <-broker.stop:
+			break send
+		case registration := <-broker.subscribingClients:
+			broker.subscribe(registration)
+		case unsubscription := <-broker.unsubscribingClients:
+			broker.unsubscribe(unsubscription)
+			if unsubscription.client == client {
+				return false
+			}
+		case update := <-broker.filterUpdates:
+			broker.updateFilter(update)
+		case resized := <-broker.resizes:
+			broker.resize(resized)
+		case grant := <-broker.grants:
+			broker.grant(grant)
+		case request := <-broker.statusRequests:
+			broker.reportStatus(request)
+		}
+	}
+	if sub.credited {
+		sub.credits++
+	}
+	return false
+}
+
+// overflow sends a message to a client without waiting, and applies the overflow policy of the client if it cannot
+// receive the message immediately. Reports whether the client received the message.
+func (broker *Broker[T]) overflow(client Client[T], sub *subscriber[T], env *envelope[T]) bool {
+	select {
+	case client <- env.message:
+		return true
+	default:
+	}
+	switch sub.overflow {
+	case DropOldest:
+		// the broker is the only sender, so the buffer of the client has space after taking the oldest message
+		select {
+		case <-client:
+		default:
+		}
+		select {
+		case client <- env.message:
+			return true
+		default:
+		}
+	case CloseSubscriber:
+		broker.unsubscribe(unsubscription[T]{client, make(chan void)})
+	}
+	return false
+}
+
 // NewBuilder constructs a new builder.
 func NewBuilder[T any]() Builder[T] {
-	return Builder[T]{defaultTimeout, defaultBufferSize}
+	return Builder[T]{timeout: defaultTimeout, bufferSize: defaultBufferSize, scheduled: defaultMaxScheduled}
+}
+
+// NewDedupBuilder constructs a new builder for a broker that suppresses consecutive identical messages.
+func NewDedupBuilder[T comparable]() Builder[T] {
+	builder := NewBuilder[T]()
+	builder.equal = func(a, b T) bool { return a == b }
+	return builder
 }
 
 // New constructs a new broker with default configuration:
 //   - timeout = 1 * time.Second
 //   - bufferSize = 10
+//   - maxScheduled = 1000
 func New[T any]() *Broker[T] {
 	return NewBuilder[T]().Build()
 }
 
+// NewDedup constructs a new broker with default configuration that suppresses consecutive identical messages.
+// A message is only broadcast if it differs from the previously broadcast message.
+func NewDedup[T comparable]() *Broker[T] {
+	return NewDedupBuilder[T]().Build()
+}
+
+// Name configures the name of the broker, which is used in errors, stats and goroutine labels.
+func (builder Builder[T]) Name(name string) Builder[T] {
+	builder.name = name
+	return builder
+}
+
 // Timeout configures the broker timeout.
 func (builder Builder[T]) Timeout(timeout time.Duration) Builder[T] {
 	builder.timeout = timeout
@@ -132,16 +796,124 @@ func (builder Builder[T]) BufferSize(bufferSize int) Builder[T] {
 	return builder
 }
 
+// ExpectedSubscribers configures the number of clients expected to subscribe to the broker,
+// so that the broker allocates the space for them upfront instead of growing repeatedly while they subscribe.
+func (builder Builder[T]) ExpectedSubscribers(subscribers int) Builder[T] {
+	builder.subscribers = subscribers
+	return builder
+}
+
+// FairPublishing configures the broker to admit concurrently blocked publishers in the order they arrived,
+// so that their messages are buffered in a global order regardless of how the runtime schedules them.
+// This serializes all publishers, which reduces the throughput of concurrent publishing.
+func (builder Builder[T]) FairPublishing() Builder[T] {
+	builder.fair = true
+	return builder
+}
+
+// Sizer configures the function estimating the size of a message in bytes, which is used by MemoryUsage.
+// By default, the size of a message is estimated by the size of its type, not including any referenced memory.
+func (builder Builder[T]) Sizer(sizer func(T) int) Builder[T] {
+	builder.sizer = sizer
+	return builder
+}
+
+// Enrich adds an enricher that attaches computed headers to every published message,
+// so that they do not need to be set by every publisher. The enricher may add, replace or remove headers.
+// Enrichers are applied in the order they were added, after the headers set by the publisher.
+func (builder Builder[T]) Enrich(enricher func(message T, headers map[string]string)) Builder[T] {
+	builder.enrichers = append(builder.enrichers, enricher)
+	return builder
+}
+
+// ExpirySweep configures the interval in which the broker discards all buffered messages whose time to live elapsed.
+// Otherwise, such messages are only discarded when it is their turn to be broadcast, holding their memory until then.
+func (builder Builder[T]) ExpirySweep(interval time.Duration) Builder[T] {
+	builder.sweep = interval
+	return builder
+}
+
+// EvictSlowClients configures the broker to unsubscribe and close clients that did not receive the given number of
+// consecutive messages in time, so that a stuck client does not delay every broadcast. The optional hook is notified
+// about every evicted client. The hook is called by the broker loop, so it must return quickly and must not call
+// the broker.
+func (builder Builder[T]) EvictSlowClients(threshold int, hook func(client Client[T])) Builder[T] {
+	builder.evictAfter, builder.evictHook = threshold, hook
+	return builder
+}
+
+// Trace configures a hook receiving the delivery record of every message after it was broadcast or discarded.
+// The hook is called by the broker loop, so it must return quickly and must not call the broker.
+func (builder Builder[T]) Trace(hook func(Trace[T])) Builder[T] {
+	builder.tracer = hook
+	return builder
+}
+
 // Build builds a new broker using the configuration of the builder.
 func (builder Builder[T]) Build() *Broker[T] {
 	broker := &Broker[T]{
-		clients:              make(map[Client[T]]void),
+		name:                 builder.name,
+		clients:              make(map[Client[T]]*subscriber[T], builder.subscribers),
 		stop:                 make(chan void),
-		subscribingClients:   make(chan Client[T]),
-		unsubscribingClients: make(chan Client[T]),
-		messages:             make(chan T, builder.bufferSize),
-		timeout:              builder.timeout,
+		done:                 make(chan void),
+		shutdown:             make(chan void),
+		drained:              make(chan void),
+		subscribingClients:   make(chan registration[T]),
+		unsubscribingClients: make(chan unsubscription[T]),
+		filterUpdates:        make(chan filterUpdate[T]),
+		statusRequests:       make(chan statusRequest[T]),
+		grants:               make(chan grant[T]),
+		resizes:              make(chan *messageBuffer[T]),
+		equal:                builder.equal,
+		route:                builder.route,
+		sizer:                builder.sizer,
+		tracer:               builder.tracer,
+		enrichers:            builder.enrichers,
+		middleware:           builder.middleware,
+		sweepInterval:        builder.sweep,
+		evictAfter:           builder.evictAfter,
+		evictHook:            builder.evictHook,
+		storage:              builder.storage,
+		wal:                  builder.wal,
+		roundRobin:           builder.roundRobin,
+		conflating:           builder.conflate,
+		maxScheduled:         int64(builder.scheduled),
+		replayAfter:          builder.replayAfter,
+	}
+	if broker.sizer == nil {
+		broker.sizer = shallowSizer[T]()
+	}
+	if builder.dedupWindow > 0 {
+		broker.dedup = newDedup(builder.dedupWindow, builder.identify)
+	}
+	if broker.replayAfter < 1 {
+		broker.replayAfter = 1
+	}
+	if broker.wal != nil {
+		broker.recoverMessages()
+	}
+	if builder.history > 0 {
+		broker.history = newHistory[T](builder.history)
+	} else if builder.retain {
+		broker.history = newHistory[T](1)
+	}
+	broker.retain = builder.retain
+	if builder.fair {
+		broker.admission = &admission{}
+	}
+	broker.buffer = newMessageBuffer[T](builder.bufferSize)
+	broker.messages.Store(broker.buffer)
+	broker.timeout.Store(int64(builder.timeout))
+	if builder.stats > 0 {
+		broker.statsBroker = NewBuilder[Stats]().Name(builder.name).Timeout(builder.timeout).Build()
+		go broker.publishStats(builder.stats)
+	}
+	if broker.name == "" {
+		go broker.run()
+	} else {
+		go pprof.Do(context.Background(), pprof.Labels("broker", broker.name), func(context.Context) {
+			broker.run()
+		})
 	}
-	go broker.run()
 	return broker
 }
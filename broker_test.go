@@ -1,6 +1,8 @@
 package broker
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -17,8 +19,8 @@ func TestNew(t *testing.T) {
 
 	broker := New[int]()
 	assertions.NotNil(broker)
-	assertions.Equal(defaultTimeout, broker.timeout)
-	assertions.Equal(defaultBufferSize, cap(broker.messages))
+	assertions.Equal(defaultTimeout, broker.currentTimeout())
+	assertions.Equal(defaultBufferSize, broker.bufferSize())
 
 	t.Cleanup(broker.Close)
 }
@@ -28,8 +30,8 @@ func TestNewBuilderDefault(t *testing.T) {
 
 	broker := NewBuilder[int]().Build()
 	assertions.NotNil(broker)
-	assertions.Equal(defaultTimeout, broker.timeout)
-	assertions.Equal(defaultBufferSize, cap(broker.messages))
+	assertions.Equal(defaultTimeout, broker.currentTimeout())
+	assertions.Equal(defaultBufferSize, broker.bufferSize())
 
 	t.Cleanup(broker.Close)
 }
@@ -40,8 +42,8 @@ func TestNewBuilderTimeout(t *testing.T) {
 	timeout := time.Millisecond
 	broker := NewBuilder[int]().Timeout(timeout).Build()
 	assertions.NotNil(broker)
-	assertions.Equal(timeout, broker.timeout)
-	assertions.Equal(defaultBufferSize, cap(broker.messages))
+	assertions.Equal(timeout, broker.currentTimeout())
+	assertions.Equal(defaultBufferSize, broker.bufferSize())
 
 	t.Cleanup(broker.Close)
 }
@@ -52,12 +54,78 @@ func TestNewBuilderBufferSize(t *testing.T) {
 	bufferSize := 100
 	broker := NewBuilder[int]().BufferSize(bufferSize).Build()
 	assertions.NotNil(broker)
-	assertions.Equal(defaultTimeout, broker.timeout)
-	assertions.Equal(bufferSize, cap(broker.messages))
+	assertions.Equal(defaultTimeout, broker.currentTimeout())
+	assertions.Equal(bufferSize, broker.bufferSize())
 
 	t.Cleanup(broker.Close)
 }
 
+func TestNewBuilderExpectedSubscribers(t *testing.T) {
+	assertions := assert.New(t)
+
+	subscribers := 1000
+	broker := NewBuilder[int]().ExpectedSubscribers(subscribers).Build()
+	assertions.NotNil(broker)
+	for i := 0; i < subscribers; i++ {
+		_, err := broker.Subscribe()
+		assertions.Nil(err)
+	}
+	assertions.Eventually(func() bool {
+		return broker.Stats().Subscribers == subscribers
+	}, time.Second, 10*time.Millisecond)
+
+	t.Cleanup(broker.Close)
+}
+
+func TestNewBuilderName(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Name("events").Timeout(100 * time.Millisecond).BufferSize(0).Build()
+	assertions.NotNil(broker)
+	assertions.Equal(`broker "events"`, broker.String())
+	assertions.Equal("events", broker.Stats().Name)
+
+	broker.Close()
+	time.Sleep(200 * time.Millisecond)
+
+	err := broker.Publish(42)
+	assertions.ErrorIs(err, ErrClosed)
+	assertions.EqualError(err, `broker "events": broker closed`)
+	assertions.EqualError(broker.Run(context.Background()), `broker "events": broker closed`)
+
+	unnamed := New[int]()
+	assertions.Equal("broker", unnamed.String())
+	unnamed.Close()
+}
+
+func TestNewDedup(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewDedup[int]()
+	assertions.NotNil(broker)
+	assertions.NotNil(broker.equal)
+
+	client, err := broker.Subscribe()
+	assertions.NotNil(client)
+	assertions.Nil(err)
+
+	for _, msg := range []int{1, 1, 2, 2, 2, 1} {
+		assertions.Nil(broker.Publish(msg))
+	}
+
+	assertions.Equal(1, <-client)
+	assertions.Equal(2, <-client)
+	assertions.Equal(1, <-client)
+
+	select {
+	case <-client:
+		assertions.Fail("Received message not expected")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	broker.Close()
+}
+
 func TestSubscribe(t *testing.T) {
 	assertions := assert.New(t)
 
@@ -81,7 +149,7 @@ func TestSubscribe(t *testing.T) {
 
 	client, err = broker.Subscribe()
 	assertions.Nil(client)
-	assertions.ErrorIs(err, ErrTimeout)
+	assertions.ErrorIs(err, ErrClosed)
 }
 
 func TestUnsubscribe(t *testing.T) {
@@ -96,10 +164,7 @@ func TestUnsubscribe(t *testing.T) {
 	assertions.Nil(err)
 
 	assertions.Nil(broker.Unsubscribe(client))
-
-	go func() {
-		assertions.Nil(broker.Publish(answer))
-	}()
+	assertions.Nil(broker.Publish(answer))
 
 	msg, ok := <-client
 	assertions.Equal(0, msg)
@@ -111,8 +176,53 @@ func TestUnsubscribe(t *testing.T) {
 
 	broker.Close()
 
-	time.Sleep(time.Second)
-	assertions.ErrorIs(broker.Unsubscribe(client), ErrTimeout)
+	assertions.ErrorIs(broker.Unsubscribe(client), ErrClosed)
+}
+
+func TestUnsubscribeSync(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// the client is closed when unsubscribe returns
+	assertions.Nil(broker.Unsubscribe(client))
+	select {
+	case _, ok := <-client:
+		assertions.False(ok)
+	default:
+		assertions.Fail("Client not closed")
+	}
+	assertions.Equal(0, broker.Stats().Subscribers)
+
+	broker.Close()
+}
+
+func TestSetFilter(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	assertions.NotNil(broker)
+
+	client, err := broker.Subscribe()
+	assertions.NotNil(client)
+	assertions.Nil(err)
+
+	assertions.Nil(broker.SetFilter(client, func(msg int) bool { return msg%2 == 0 }))
+	for msg := 1; msg <= 4; msg++ {
+		assertions.Nil(broker.Publish(msg))
+	}
+	assertions.Equal(2, <-client)
+	assertions.Equal(4, <-client)
+
+	assertions.Nil(broker.SetFilter(client, nil))
+	assertions.Nil(broker.Publish(5))
+	assertions.Equal(5, <-client)
+
+	broker.Close()
+
+	assertions.ErrorIs(broker.SetFilter(client, nil), ErrClosed)
 }
 
 func TestClose(t *testing.T) {
@@ -129,9 +239,11 @@ func TestClose(t *testing.T) {
 	broker.Close()
 	assertions.Panics(broker.Close)
 
-	time.Sleep(time.Second)
-
-	assertions.ErrorIs(broker.Publish(answer), ErrTimeout)
+	// operations on the closed broker fail immediately instead of timing out
+	start := time.Now()
+	assertions.ErrorIs(broker.Publish(answer), ErrClosed)
+	assertions.ErrorIs(broker.Unsubscribe(client), ErrClosed)
+	assertions.Less(time.Since(start), 100*time.Millisecond)
 
 	msg, ok := <-client
 	assertions.Equal(0, msg)
@@ -139,7 +251,60 @@ func TestClose(t *testing.T) {
 
 	client, err = broker.Subscribe()
 	assertions.Nil(client)
-	assertions.ErrorIs(err, ErrTimeout)
+	assertions.ErrorIs(err, ErrClosed)
+}
+
+func TestRun(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		done <- broker.Run(ctx)
+	}()
+
+	assertions.Nil(broker.CloseReason())
+	assertions.False(broker.IsClosed())
+	cancel()
+	assertions.ErrorIs(<-done, context.Canceled)
+	assertions.ErrorIs(broker.Run(context.Background()), context.Canceled)
+	assertions.ErrorIs(broker.CloseReason(), context.Canceled)
+	assertions.True(broker.IsClosed())
+	assertions.Panics(broker.Close)
+}
+
+func TestRunCause(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	done := make(chan error)
+	go func() {
+		done <- broker.Run(ctx)
+	}()
+
+	cause := errors.New("shutdown")
+	cancel(cause)
+	assertions.ErrorIs(<-done, cause)
+	assertions.ErrorIs(broker.CloseReason(), cause)
+}
+
+func TestRunClosed(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+
+	done := make(chan error)
+	go func() {
+		done <- broker.Run(context.Background())
+	}()
+
+	broker.Close()
+	assertions.ErrorIs(<-done, ErrClosed)
+	assertions.ErrorIs(broker.CloseReason(), ErrClosed)
 }
 
 func TestPublishTimeout(t *testing.T) {
@@ -203,3 +368,298 @@ func BenchmarkSubscribe(b *testing.B) {
 		broker.Close()
 	})
 }
+
+func TestControlDuringBroadcast(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(time.Second).Build()
+	slow, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// block the broker loop while it sends the first message to the slow client
+	assertions.Nil(broker.Publish(1))
+	time.Sleep(50 * time.Millisecond)
+
+	// control requests are served while the broker waits for the slow client
+	start := time.Now()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+	assertions.Nil(broker.SetFilter(client, nil))
+	_, err = broker.SubscriberStatus(client)
+	assertions.Nil(err)
+	assertions.Nil(broker.Unsubscribe(client))
+	_, ok := <-client
+	assertions.False(ok)
+
+	// unsubscribing the slow client stops waiting for it
+	assertions.Nil(broker.Unsubscribe(slow))
+	_, ok = <-slow
+	assertions.False(ok)
+	assertions.Less(time.Since(start), 500*time.Millisecond)
+
+	assertions.Eventually(func() bool {
+		return broker.Stats().Broadcasts == 1
+	}, 500*time.Millisecond, 10*time.Millisecond)
+	assertions.Zero(broker.Stats().Dropped)
+
+	broker.Close()
+}
+
+func TestCloseDuringBroadcast(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(time.Minute).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// block the broker loop while it sends the first message
+	assertions.Nil(broker.Publish(1))
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		done <- broker.Run(context.Background())
+	}()
+	broker.Close()
+	select {
+	case err := <-done:
+		assertions.ErrorIs(err, ErrClosed)
+	case <-time.After(time.Second):
+		assertions.Fail("Broker not stopped during broadcast")
+	}
+	_, ok := <-client
+	assertions.False(ok)
+}
+
+func TestSubscribeWithCatchUp(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(time.Second).Build()
+	slow, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// block the broker loop while it sends the first message to the slow client
+	assertions.Nil(broker.Publish(1))
+	assertions.Nil(broker.Publish(2))
+	time.Sleep(50 * time.Millisecond)
+
+	// a client subscribed with catch up receives the message being broadcast, and all buffered messages
+	client, err := broker.Subscribe(WithCatchUp())
+	assertions.Nil(err)
+
+	for _, msg := range []int{1, 2} {
+		received := make(chan int, 2)
+		for _, c := range []Client[int]{slow, client} {
+			go func(c Client[int]) {
+				received <- <-c
+			}(c)
+		}
+		assertions.Equal(msg, <-received)
+		assertions.Equal(msg, <-received)
+	}
+
+	broker.Close()
+}
+
+func TestContextVariants(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Name("events").Timeout(time.Minute).BufferSize(0).Build()
+	client, err := broker.SubscribeContext(context.Background())
+	assertions.Nil(err)
+
+	// block the broker loop while it sends the first message
+	assertions.Nil(broker.PublishContext(context.Background(), 1))
+	time.Sleep(50 * time.Millisecond)
+
+	// the context is done before the broker accepts the message, instead of the broker timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = broker.PublishContext(ctx, 2)
+	assertions.ErrorIs(err, context.DeadlineExceeded)
+	assertions.EqualError(err, `broker "events": context deadline exceeded`)
+
+	assertions.Equal(1, <-client)
+	assertions.Nil(broker.UnsubscribeContext(context.Background(), client))
+
+	// block the broker loop while it evaluates the filter, so that it does not accept any requests
+	filtering, release := make(chan void), make(chan void)
+	blocked, err := broker.Subscribe(WithBufferSize(1), WithFilter(func(int) bool {
+		filtering <- void{}
+		<-release
+		return true
+	}))
+	assertions.Nil(err)
+	assertions.Nil(broker.PublishContext(context.Background(), 3))
+	<-filtering
+
+	canceled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	_, err = broker.SubscribeContext(canceled)
+	assertions.ErrorIs(err, context.Canceled)
+	assertions.ErrorIs(broker.UnsubscribeContext(canceled, blocked), context.Canceled)
+	close(release)
+	assertions.Equal(3, <-blocked)
+
+	broker.Close()
+}
+
+func TestPublishContextWithPublishTimeout(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(time.Minute).BufferSize(0).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// block the broker loop while it sends the first message
+	assertions.Nil(broker.Publish(1))
+	time.Sleep(50 * time.Millisecond)
+
+	// the publish timeout expires before the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	start := time.Now()
+	assertions.ErrorIs(broker.PublishContext(ctx, 2, WithPublishTimeout(50*time.Millisecond)), ErrTimeout)
+	assertions.Less(time.Since(start), time.Second)
+
+	// the context is done before the publish timeout expires, and its error is wrapped by the unnamed broker
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+	err = broker.PublishContext(short, 3, WithPublishTimeout(time.Minute))
+	assertions.ErrorIs(err, context.DeadlineExceeded)
+	assertions.EqualError(err, "broker: context deadline exceeded")
+
+	assertions.Equal(1, <-client)
+	broker.Close()
+}
+
+func TestCloseWithContext(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+	for msg := 1; msg <= 3; msg++ {
+		assertions.Nil(broker.Publish(msg))
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- broker.CloseWithContext(context.Background())
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// no messages are accepted after the shutdown started, but the buffered messages are still broadcast
+	assertions.ErrorIs(broker.Publish(4), ErrClosed)
+	var received []int
+	for msg := range client {
+		received = append(received, msg)
+	}
+	assertions.Equal([]int{1, 2, 3}, received)
+	assertions.Nil(<-done)
+	assertions.ErrorIs(broker.CloseReason(), ErrClosed)
+	assertions.Panics(broker.Close)
+}
+
+func TestCloseWithContextTimeout(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(time.Minute).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+	assertions.Nil(broker.Publish(1))
+	assertions.Nil(broker.Publish(2))
+
+	// the client does not receive the buffered messages before the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assertions.ErrorIs(broker.CloseWithContext(ctx), context.DeadlineExceeded)
+	_, ok := <-client
+	assertions.False(ok)
+}
+
+func TestWaitClosed(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assertions.ErrorIs(broker.WaitClosed(ctx), context.DeadlineExceeded)
+	select {
+	case <-broker.Done():
+		assertions.Fail("Broker not closed yet")
+	default:
+	}
+
+	broker.Close()
+	assertions.Nil(broker.WaitClosed(context.Background()))
+	<-broker.Done()
+	select {
+	case _, ok := <-client:
+		assertions.False(ok)
+	default:
+		assertions.Fail("Client not closed")
+	}
+}
+
+func TestEvictSlowClients(t *testing.T) {
+	assertions := assert.New(t)
+
+	evicted := make(chan Client[int], 1)
+	broker := NewBuilder[int]().
+		Timeout(10*time.Millisecond).
+		EvictSlowClients(2, func(client Client[int]) { evicted <- client }).
+		Build()
+	slow, err := broker.Subscribe()
+	assertions.Nil(err)
+	fast, err := broker.Subscribe()
+	assertions.Nil(err)
+	go func() {
+		for range fast {
+		}
+	}()
+
+	for msg := 1; msg <= 3; msg++ {
+		assertions.Nil(broker.Publish(msg))
+	}
+	assertions.Equal(slow, <-evicted)
+	_, ok := <-slow
+	assertions.False(ok)
+	assertions.Eventually(func() bool {
+		return broker.Stats().Broadcasts == 3
+	}, time.Second, 10*time.Millisecond)
+	assertions.Equal(1, broker.Stats().Subscribers)
+	assertions.Equal(uint64(2), broker.Stats().Dropped)
+
+	// the status of the evicted client is reported once
+	status, err := broker.SubscriberStatus(slow)
+	assertions.Nil(err)
+	assertions.Equal(Evicted, status.State)
+	assertions.Equal(uint64(2), status.Dropped)
+	_, err = broker.SubscriberStatus(slow)
+	assertions.ErrorIs(err, ErrNotSubscribed)
+
+	broker.Close()
+}
+
+func TestUnsubscribeEvictedClient(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(10*time.Millisecond).EvictSlowClients(1, nil).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	assertions.Nil(broker.Publish(1))
+	assertions.Eventually(func() bool {
+		return broker.Stats().Subscribers == 0
+	}, time.Second, 10*time.Millisecond)
+	// the client was closed by the broker already
+	assertions.Nil(broker.Unsubscribe(client))
+	_, ok := <-client
+	assertions.False(ok)
+
+	broker.Close()
+}
@@ -0,0 +1,263 @@
+// Package brokerpubsub provides a gocloud.dev/pubsub driver backed by go-broker,
+// so code written against the portable pub/sub API of the Go CDK can use a broker as in-process transport.
+package brokerpubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mpe85/go-broker"
+	"gocloud.dev/gcerrors"
+	"gocloud.dev/pubsub"
+	"gocloud.dev/pubsub/driver"
+)
+
+// Message is the message type carried by brokers used with this driver.
+type Message struct {
+	// Body contains the content of the message.
+	Body []byte
+	// Metadata has key/value pairs describing the message.
+	Metadata map[string]string
+}
+
+// errClosed is the error returned when the broker closed the client of a subscription.
+var errClosed = errors.New("broker closed")
+
+// receiveWait specifies how long ReceiveBatch waits for a message before it returns no messages.
+const receiveWait = time.Second
+
+// NewTopic constructs a new topic that publishes messages to the broker.
+func NewTopic(theBroker *broker.Broker[*Message]) *pubsub.Topic {
+	return pubsub.NewTopic(&topic{theBroker}, nil)
+}
+
+// NewSubscription subscribes to the broker and constructs a new subscription receiving its messages.
+// Messages that are not acknowledged are not delivered again, unless they are negatively acknowledged.
+// Returns broker.ErrTimeout on timeout, or broker.ErrClosed if the broker is closed.
+func NewSubscription(theBroker *broker.Broker[*Message]) (*pubsub.Subscription, error) {
+	client, err := theBroker.Subscribe()
+	if err != nil {
+		return nil, err
+	}
+	sub := &subscription{
+		broker:      theBroker,
+		client:      client,
+		outstanding: make(map[uint64]*Message),
+	}
+	return pubsub.NewSubscription(sub, nil, nil), nil
+}
+
+// topic implements driver.Topic.
+type topic struct {
+	broker *broker.Broker[*Message]
+}
+
+// SendBatch implements driver.Topic.SendBatch.
+func (t *topic) SendBatch(ctx context.Context, ms []*driver.Message) error {
+	for _, m := range ms {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if m.BeforeSend != nil {
+			if err := m.BeforeSend(asNothing); err != nil {
+				return err
+			}
+		}
+		if err := t.broker.Publish(&Message{Body: m.Body, Metadata: m.Metadata}); err != nil {
+			return err
+		}
+		if m.AfterSend != nil {
+			if err := m.AfterSend(asNothing); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// IsRetryable implements driver.Topic.IsRetryable.
+func (*topic) IsRetryable(error) bool {
+	return false
+}
+
+// As implements driver.Topic.As.
+func (t *topic) As(i any) bool {
+	if p, ok := i.(**broker.Broker[*Message]); ok {
+		*p = t.broker
+		return true
+	}
+	return false
+}
+
+// ErrorAs implements driver.Topic.ErrorAs.
+func (*topic) ErrorAs(error, any) bool {
+	return false
+}
+
+// ErrorCode implements driver.Topic.ErrorCode.
+func (*topic) ErrorCode(err error) gcerrors.ErrorCode {
+	return errorCode(err)
+}
+
+// Close implements driver.Topic.Close.
+func (*topic) Close() error {
+	return nil
+}
+
+// subscription implements driver.Subscription.
+type subscription struct {
+	broker      *broker.Broker[*Message]
+	client      broker.Client[*Message]
+	mutex       sync.Mutex
+	nextAckID   uint64
+	outstanding map[uint64]*Message
+	nacked      []uint64
+}
+
+// ReceiveBatch implements driver.Subscription.ReceiveBatch.
+// Negatively acknowledged messages are received first, then it waits for messages from the broker.
+func (s *subscription) ReceiveBatch(ctx context.Context, maxMessages int) ([]*driver.Message, error) {
+	if ms := s.receiveNacked(maxMessages); len(ms) > 0 {
+		return ms, nil
+	}
+
+	var ms []*driver.Message
+	select {
+	case msg, ok := <-s.client:
+		if !ok {
+			return nil, errClosed
+		}
+		ms = append(ms, s.track(msg))
+	case <-time.After(receiveWait):
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	for len(ms) < maxMessages {
+		select {
+		case msg, ok := <-s.client:
+			if !ok {
+				return ms, nil
+			}
+			ms = append(ms, s.track(msg))
+		default:
+			return ms, nil
+		}
+	}
+	return ms, nil
+}
+
+// receiveNacked returns up to maxMessages negatively acknowledged messages.
+func (s *subscription) receiveNacked(maxMessages int) []*driver.Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var ms []*driver.Message
+	for len(s.nacked) > 0 && len(ms) < maxMessages {
+		ackID := s.nacked[0]
+		s.nacked = s.nacked[1:]
+		if msg, ok := s.outstanding[ackID]; ok {
+			ms = append(ms, toDriverMessage(ackID, msg))
+		}
+	}
+	return ms
+}
+
+// track registers a message received from the broker as outstanding until it is acknowledged.
+func (s *subscription) track(msg *Message) *driver.Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.nextAckID++
+	s.outstanding[s.nextAckID] = msg
+	return toDriverMessage(s.nextAckID, msg)
+}
+
+// SendAcks implements driver.Subscription.SendAcks.
+func (s *subscription) SendAcks(ctx context.Context, ackIDs []driver.AckID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, ackID := range ackIDs {
+		delete(s.outstanding, ackID.(uint64))
+	}
+	return nil
+}
+
+// CanNack implements driver.Subscription.CanNack.
+func (*subscription) CanNack() bool {
+	return true
+}
+
+// SendNacks implements driver.Subscription.SendNacks.
+func (s *subscription) SendNacks(ctx context.Context, ackIDs []driver.AckID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, ackID := range ackIDs {
+		s.nacked = append(s.nacked, ackID.(uint64))
+	}
+	return nil
+}
+
+// IsRetryable implements driver.Subscription.IsRetryable.
+func (*subscription) IsRetryable(error) bool {
+	return false
+}
+
+// As implements driver.Subscription.As.
+func (s *subscription) As(i any) bool {
+	if p, ok := i.(*broker.Client[*Message]); ok {
+		*p = s.client
+		return true
+	}
+	return false
+}
+
+// ErrorAs implements driver.Subscription.ErrorAs.
+func (*subscription) ErrorAs(error, any) bool {
+	return false
+}
+
+// ErrorCode implements driver.Subscription.ErrorCode.
+func (*subscription) ErrorCode(err error) gcerrors.ErrorCode {
+	return errorCode(err)
+}
+
+// Close implements driver.Subscription.Close by unsubscribing from the broker.
+func (s *subscription) Close() error {
+	return s.broker.Unsubscribe(s.client)
+}
+
+// toDriverMessage converts a broker message to a driver message.
+func toDriverMessage(ackID uint64, msg *Message) *driver.Message {
+	return &driver.Message{
+		LoggableID: fmt.Sprintf("msg #%d", ackID),
+		Body:       msg.Body,
+		Metadata:   msg.Metadata,
+		AckID:      ackID,
+		AsFunc:     asNothing,
+	}
+}
+
+// asNothing is the As function of messages, which do not expose any driver-specific types.
+func asNothing(any) bool {
+	return false
+}
+
+// errorCode returns the code describing an error returned by the driver.
+func errorCode(err error) gcerrors.ErrorCode {
+	switch {
+	case errors.Is(err, broker.ErrTimeout):
+		return gcerrors.DeadlineExceeded
+	case errors.Is(err, errClosed), errors.Is(err, broker.ErrClosed):
+		return gcerrors.FailedPrecondition
+	default:
+		return gcerrors.Unknown
+	}
+}
@@ -0,0 +1,89 @@
+package brokerpubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mpe85/go-broker"
+	"github.com/stretchr/testify/assert"
+	"gocloud.dev/gcerrors"
+	"gocloud.dev/pubsub"
+)
+
+func TestSendReceive(t *testing.T) {
+	assertions := assert.New(t)
+	ctx := context.Background()
+
+	theBroker := broker.NewBuilder[*Message]().Timeout(100 * time.Millisecond).Build()
+	t.Cleanup(theBroker.Close)
+
+	topic := NewTopic(theBroker)
+	sub, err := NewSubscription(theBroker)
+	assertions.Nil(err)
+
+	var b *broker.Broker[*Message]
+	assertions.True(topic.As(&b))
+	assertions.Same(theBroker, b)
+	var client broker.Client[*Message]
+	assertions.True(sub.As(&client))
+	assertions.NotNil(client)
+
+	assertions.Nil(topic.Send(ctx, &pubsub.Message{Body: []byte("Hello"), Metadata: map[string]string{"key": "value"}}))
+
+	msg, err := sub.Receive(ctx)
+	assertions.Nil(err)
+	assertions.Equal([]byte("Hello"), msg.Body)
+	assertions.Equal(map[string]string{"key": "value"}, msg.Metadata)
+	assertions.True(msg.Nackable())
+	msg.Nack()
+
+	msg, err = sub.Receive(ctx)
+	assertions.Nil(err)
+	assertions.Equal([]byte("Hello"), msg.Body)
+	msg.Ack()
+
+	assertions.Nil(sub.Shutdown(ctx))
+	assertions.Nil(topic.Shutdown(ctx))
+}
+
+func TestReceiveClosed(t *testing.T) {
+	assertions := assert.New(t)
+	ctx := context.Background()
+
+	theBroker := broker.NewBuilder[*Message]().Timeout(100 * time.Millisecond).Build()
+	sub, err := NewSubscription(theBroker)
+	assertions.Nil(err)
+
+	theBroker.Close()
+
+	_, err = sub.Receive(ctx)
+	assertions.Equal(gcerrors.FailedPrecondition, gcerrors.Code(err))
+	assertions.Equal(gcerrors.FailedPrecondition, gcerrors.Code(sub.Shutdown(ctx)))
+}
+
+func TestSendClosed(t *testing.T) {
+	assertions := assert.New(t)
+	ctx := context.Background()
+
+	theBroker := broker.NewBuilder[*Message]().Timeout(100 * time.Millisecond).BufferSize(0).Build()
+	theBroker.Close()
+	time.Sleep(200 * time.Millisecond)
+
+	topic := NewTopic(theBroker)
+	err := topic.Send(ctx, &pubsub.Message{Body: []byte("Hello")})
+	assertions.Equal(gcerrors.FailedPrecondition, gcerrors.Code(err))
+	assertions.Nil(topic.Shutdown(ctx))
+}
+
+func TestNewSubscriptionClosed(t *testing.T) {
+	assertions := assert.New(t)
+
+	theBroker := broker.NewBuilder[*Message]().Timeout(100 * time.Millisecond).Build()
+	theBroker.Close()
+	time.Sleep(200 * time.Millisecond)
+
+	sub, err := NewSubscription(theBroker)
+	assertions.Nil(sub)
+	assertions.ErrorIs(err, broker.ErrClosed)
+}
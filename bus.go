@@ -0,0 +1,179 @@
+package broker
+
+import (
+	"sort"
+	"sync"
+)
+
+// Bus is an event bus facade over a broker, which calls registered handlers for every emitted event.
+// Handlers are organized in named groups. Each group receives all events from the broker in its own goroutine,
+// and calls its handlers one after another in their order. Each group buffers as many received events as the broker,
+// so that a slow group delays other groups only once its buffer is full, and then for at most the broker timeout
+// per event, after which the group misses the event.
+type Bus[T any] struct {
+	mutex  sync.Mutex
+	broker *Broker[T]
+	groups map[string]*busGroup[T]
+}
+
+// busGroup holds the handlers of a group, and the client receiving the events for them.
+type busGroup[T any] struct {
+	mutex    sync.Mutex
+	handlers []busHandler[T]
+	client   Client[T]
+	done     chan void
+}
+
+// busHandler holds a registered handler and its order.
+type busHandler[T any] struct {
+	handle func(T)
+	order  int
+}
+
+// HandlerOption configures the registration of a handler.
+type HandlerOption func(*handlerOptions)
+
+// handlerOptions holds the configuration applied by handler options.
+type handlerOptions struct {
+	group string
+	order int
+}
+
+// InGroup configures the group of a handler. By default, handlers are registered in the group with the empty name.
+func InGroup(group string) HandlerOption {
+	return func(options *handlerOptions) {
+		options.group = group
+	}
+}
+
+// WithOrder configures the order of a handler within its group.
+// Handlers with a lower order are called first, handlers with the same order in the order they were registered.
+// The default order is 0.
+func WithOrder(order int) HandlerOption {
+	return func(options *handlerOptions) {
+		options.order = order
+	}
+}
+
+// NewBus constructs a new event bus over a broker with default configuration.
+func NewBus[T any]() *Bus[T] {
+	return NewBusOver(New[T]())
+}
+
+// NewBusOver constructs a new event bus over a broker, which is closed when the bus is closed.
+func NewBusOver[T any](broker *Broker[T]) *Bus[T] {
+	return &Bus[T]{broker: broker, groups: make(map[string]*busGroup[T])}
+}
+
+// On registers a handler that is called for every subsequently emitted event.
+// Returns ErrTimeout on timeout.
+func (bus *Bus[T]) On(handler func(event T), opts ...HandlerOption) error {
+	var options handlerOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	group, ok := bus.groups[options.group]
+	if !ok {
+		client, err := bus.broker.Subscribe(WithBufferSize(bus.broker.bufferSize()))
+		if err != nil {
+			return err
+		}
+		group = &busGroup[T]{client: client, done: make(chan void)}
+		bus.groups[options.group] = group
+		go group.run()
+	}
+	group.add(busHandler[T]{handle: handler, order: options.order})
+	return nil
+}
+
+// Off removes all handlers of a group. Has no effect if the group has no handlers.
+// Returns ErrTimeout on timeout.
+func (bus *Bus[T]) Off(group string) error {
+	bus.mutex.Lock()
+	g, ok := bus.groups[group]
+	if !ok {
+		bus.mutex.Unlock()
+		return nil
+	}
+	if err := bus.broker.Unsubscribe(g.client); err != nil {
+		bus.mutex.Unlock()
+		return err
+	}
+	delete(bus.groups, group)
+	bus.mutex.Unlock()
+	<-g.done
+	return nil
+}
+
+// Emit publishes an event to the bus, whose handlers are called asynchronously.
+// Returns ErrTimeout on timeout.
+func (bus *Bus[T]) Emit(event T) error {
+	return bus.broker.Publish(event)
+}
+
+// EmitSync calls all handlers of the bus for an event synchronously, group by group in the order of their names.
+// The event bypasses the broker, so the handlers may be called concurrently with asynchronously emitted events.
+func (bus *Bus[T]) EmitSync(event T) {
+	bus.mutex.Lock()
+	names := make([]string, 0, len(bus.groups))
+	for name := range bus.groups {
+		names = append(names, name)
+	}
+	groups := make([]*busGroup[T], 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		groups = append(groups, bus.groups[name])
+	}
+	bus.mutex.Unlock()
+	for _, group := range groups {
+		group.handle(event)
+	}
+}
+
+// Close closes the broker of the bus, and waits until all groups handled the events they already received.
+// Events still buffered by the broker are discarded.
+// Panics when the bus is already closed.
+func (bus *Bus[T]) Close() {
+	bus.mutex.Lock()
+	bus.broker.Close()
+	groups := bus.groups
+	bus.groups = make(map[string]*busGroup[T])
+	bus.mutex.Unlock()
+	for _, group := range groups {
+		<-group.done
+	}
+}
+
+// add adds a handler to the group, keeping the handlers sorted by their order.
+func (group *busGroup[T]) add(handler busHandler[T]) {
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+	i := sort.Search(len(group.handlers), func(i int) bool {
+		return group.handlers[i].order > handler.order
+	})
+	// copy the handlers, as they may be called concurrently
+	handlers := make([]busHandler[T], 0, len(group.handlers)+1)
+	handlers = append(handlers, group.handlers[:i]...)
+	handlers = append(handlers, handler)
+	group.handlers = append(handlers, group.handlers[i:]...)
+}
+
+// handle calls all handlers of the group for an event.
+func (group *busGroup[T]) handle(event T) {
+	group.mutex.Lock()
+	handlers := group.handlers
+	group.mutex.Unlock()
+	for _, handler := range handlers {
+		handler.handle(event)
+	}
+}
+
+// run starts the group loop, which handles the events until the client is closed.
+func (group *busGroup[T]) run() {
+	defer close(group.done)
+	for event := range group.client {
+		group.handle(event)
+	}
+}
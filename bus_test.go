@@ -0,0 +1,78 @@
+package broker
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBus(t *testing.T) {
+	assertions := assert.New(t)
+
+	bus := NewBus[int]()
+	var mutex sync.Mutex
+	var calls []string
+	handler := func(name string) func(int) {
+		return func(event int) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			calls = append(calls, name)
+		}
+	}
+	assertions.Nil(bus.On(handler("second"), WithOrder(1)))
+	assertions.Nil(bus.On(handler("first")))
+	assertions.Nil(bus.On(handler("third"), WithOrder(1)))
+	handled := make(chan int)
+	assertions.Nil(bus.On(func(event int) { handled <- event }, InGroup("other")))
+
+	assertions.Nil(bus.Emit(1))
+	assertions.Equal(1, <-handled)
+
+	// the other group is called after the default group, which handles the event synchronously
+	go bus.EmitSync(2)
+	assertions.Equal(2, <-handled)
+
+	assertions.Nil(bus.Off("other"))
+	assertions.Nil(bus.Off("unknown"))
+	assertions.Nil(bus.Emit(3))
+	assertions.Eventually(func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(calls) == 9
+	}, time.Second, 10*time.Millisecond)
+
+	bus.Close()
+	assertions.Equal([]string{"first", "second", "third", "first", "second", "third", "first", "second", "third"}, calls)
+}
+
+func TestBusSlowGroup(t *testing.T) {
+	assertions := assert.New(t)
+
+	bus := NewBusOver(NewBuilder[int]().BufferSize(2).Timeout(time.Second).Build())
+	release := make(chan void)
+	slow := make(chan int, 3)
+	assertions.Nil(bus.On(func(event int) {
+		<-release
+		slow <- event
+	}, InGroup("slow")))
+	fast := make(chan int, 3)
+	assertions.Nil(bus.On(func(event int) { fast <- event }, InGroup("fast")))
+
+	// the slow group buffers the events it cannot handle yet, so that the fast group is not delayed
+	start := time.Now()
+	for event := 0; event < 3; event++ {
+		assertions.Nil(bus.Emit(event))
+	}
+	for event := 0; event < 3; event++ {
+		assertions.Equal(event, <-fast)
+	}
+	assertions.Less(time.Since(start), 500*time.Millisecond)
+
+	close(release)
+	for event := 0; event < 3; event++ {
+		assertions.Equal(event, <-slow)
+	}
+	bus.Close()
+}
@@ -0,0 +1,366 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Config describes a topology of named brokers and the routes between them.
+// It can be loaded from JSON with LoadConfig.
+type Config struct {
+	// Brokers configures the named brokers of the topology.
+	Brokers map[string]BrokerConfig `json:"brokers"`
+	// Routes configures the routes between the brokers.
+	Routes []RouteConfig `json:"routes"`
+}
+
+// BrokerConfig configures a single broker. Unset values keep the default configuration.
+type BrokerConfig struct {
+	// Timeout configures the broker timeout, like "500ms".
+	Timeout Duration `json:"timeout"`
+	// BufferSize configures the message buffer size.
+	BufferSize *int `json:"bufferSize"`
+}
+
+// RouteConfig configures a route republishing messages from one broker to other brokers.
+type RouteConfig struct {
+	// From is the name of the source broker.
+	From string `json:"from"`
+	// Predicate is the name of the predicate matching the routed messages, empty to route all messages.
+	Predicate string `json:"predicate"`
+	// To are the names of the destination brokers.
+	To []string `json:"to"`
+}
+
+// Duration is a time.Duration that is encoded as string, like "1m30s".
+type Duration time.Duration
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (duration *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*duration = Duration(parsed)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (duration Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(duration).String()), nil
+}
+
+// ErrInvalidConfig is the error returned when a config refers to unknown brokers or predicates,
+// or configures negative values.
+var ErrInvalidConfig = errors.New("invalid config")
+
+// validate checks that the config does not configure a negative timeout or buffer size.
+func (config BrokerConfig) validate() error {
+	if config.Timeout < 0 {
+		return fmt.Errorf("%w: negative timeout %v", ErrInvalidConfig, time.Duration(config.Timeout))
+	}
+	if config.BufferSize != nil && *config.BufferSize < 0 {
+		return fmt.Errorf("%w: negative buffer size %d", ErrInvalidConfig, *config.BufferSize)
+	}
+	return nil
+}
+
+// LoadConfig reads a JSON config from the reader.
+func LoadConfig(reader io.Reader) (Config, error) {
+	var config Config
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&config)
+	return config, err
+}
+
+// ApplyConfig applies a broker config to the running broker, without affecting the subscribed clients.
+// A changed buffer size migrates all buffered messages to the resized buffer, preserving their order.
+// The broker loop switches the buffers, so neither publishers nor the caller wait for each other, and publishers
+// still waiting to send to the previous buffer complete their sends to it.
+// Returns ErrInvalidConfig if the config configures negative values, or ErrClosed if the broker is closed.
+func (broker *Broker[T]) ApplyConfig(config BrokerConfig) error {
+	if err := config.validate(); err != nil {
+		return broker.error(err)
+	}
+	broker.configMutex.Lock()
+	defer broker.configMutex.Unlock()
+	if broker.closed.Load() {
+		return broker.error(ErrClosed)
+	}
+	if config.Timeout > 0 {
+		broker.timeout.Store(int64(config.Timeout))
+	}
+	if config.BufferSize == nil {
+		return nil
+	}
+
+	if broker.bufferSize() == *config.BufferSize {
+		return nil
+	}
+	// publishers send to the resized buffer right away, but the broker loop receives from it only after the migration
+	resized := newMessageBuffer[T](*config.BufferSize)
+	broker.messages.Store(resized)
+	select {
+	case broker.resizes <- resized:
+		return nil
+	case <-broker.done:
+		return broker.error(ErrClosed)
+	}
+}
+
+// resize migrates all buffered messages to the pending messages, and switches to the resized buffer.
+// The previous buffer is retired, until the publishers that acquired it before are done sending to it.
+func (broker *Broker[T]) resize(resized *messageBuffer[T]) {
+	for len(broker.buffer.messages) > 0 {
+		broker.enqueue(<-broker.buffer.messages)
+	}
+	broker.retired = append(broker.retired, broker.buffer)
+	broker.buffer = resized
+}
+
+// retire drops the retired buffers that no publisher sends to anymore, and have been drained,
+// and returns the oldest remaining retired buffer to receive from, or nil if there is none.
+func (broker *Broker[T]) retire() chan envelope[T] {
+	for len(broker.retired) > 0 {
+		// check the senders first, as a sender releases the buffer only after sending to it
+		buffer := broker.retired[0]
+		if buffer.senders.Load() > 0 || len(buffer.messages) > 0 {
+			return buffer.messages
+		}
+		broker.retired[0] = nil
+		broker.retired = broker.retired[1:]
+	}
+	return nil
+}
+
+// Topology holds the named brokers and routers built from a config.
+type Topology[T any] struct {
+	mutex   sync.Mutex
+	builder TopologyBuilder[T]
+	brokers map[string]*Broker[T]
+	routers map[string]*Router[T]
+}
+
+// TopologyBuilder encapsulates the construction of a new topology from a config.
+type TopologyBuilder[T any] struct {
+	config     Config
+	predicates map[string]func(T) bool
+	overrides  map[string][]func(Builder[T]) Builder[T]
+}
+
+// NewTopologyBuilder constructs a new topology builder using the config.
+func NewTopologyBuilder[T any](config Config) TopologyBuilder[T] {
+	return TopologyBuilder[T]{
+		config:     config,
+		predicates: make(map[string]func(T) bool),
+		overrides:  make(map[string][]func(Builder[T]) Builder[T]),
+	}
+}
+
+// Predicate registers a named predicate that routes of the config can refer to.
+func (builder TopologyBuilder[T]) Predicate(name string, predicate func(T) bool) TopologyBuilder[T] {
+	builder.predicates[name] = predicate
+	return builder
+}
+
+// Override registers a programmatic override for the named broker,
+// which is applied to its builder after the values of the config.
+func (builder TopologyBuilder[T]) Override(name string, override func(Builder[T]) Builder[T]) TopologyBuilder[T] {
+	builder.overrides[name] = append(builder.overrides[name], override)
+	return builder
+}
+
+// Build builds the brokers and routers of the config.
+// Returns ErrInvalidConfig if the config refers to unknown brokers or predicates or configures negative values,
+// or ErrTimeout on timeout.
+func (builder TopologyBuilder[T]) Build() (*Topology[T], error) {
+	if err := builder.validate(); err != nil {
+		return nil, err
+	}
+	topology := &Topology[T]{
+		builder: builder,
+		brokers: make(map[string]*Broker[T], len(builder.config.Brokers)),
+		routers: make(map[string]*Router[T]),
+	}
+	for name, config := range builder.config.Brokers {
+		topology.brokers[name] = builder.buildBroker(name, config)
+	}
+	routers, err := topology.newRouters(topology.brokers, builder.config.Routes)
+	if err != nil {
+		_ = topology.Close()
+		return nil, err
+	}
+	topology.routers = routers
+	topology.replaceRoutes(builder.config.Routes)
+	return topology, nil
+}
+
+// buildBroker builds a named broker using the config values and the overrides.
+func (builder TopologyBuilder[T]) buildBroker(name string, config BrokerConfig) *Broker[T] {
+	brokerBuilder := NewBuilder[T]().Name(name)
+	if config.Timeout > 0 {
+		brokerBuilder = brokerBuilder.Timeout(time.Duration(config.Timeout))
+	}
+	if config.BufferSize != nil {
+		brokerBuilder = brokerBuilder.BufferSize(*config.BufferSize)
+	}
+	for _, override := range builder.overrides[name] {
+		brokerBuilder = override(brokerBuilder)
+	}
+	return brokerBuilder.Build()
+}
+
+// validate checks that the config only refers to known brokers and predicates, and configures no negative values.
+func (builder TopologyBuilder[T]) validate() error {
+	for name, config := range builder.config.Brokers {
+		if err := config.validate(); err != nil {
+			return fmt.Errorf("broker %q: %w", name, err)
+		}
+	}
+	for name := range builder.overrides {
+		if _, ok := builder.config.Brokers[name]; !ok {
+			return fmt.Errorf("%w: override of unknown broker %q", ErrInvalidConfig, name)
+		}
+	}
+	for _, routeConfig := range builder.config.Routes {
+		for _, name := range append([]string{routeConfig.From}, routeConfig.To...) {
+			if _, ok := builder.config.Brokers[name]; !ok {
+				return fmt.Errorf("%w: route refers to unknown broker %q", ErrInvalidConfig, name)
+			}
+		}
+		if _, ok := builder.predicates[routeConfig.Predicate]; routeConfig.Predicate != "" && !ok {
+			return fmt.Errorf("%w: route refers to unknown predicate %q", ErrInvalidConfig, routeConfig.Predicate)
+		}
+	}
+	return nil
+}
+
+// ApplyConfig applies a changed config to the running topology, without affecting the subscribed clients.
+// Existing brokers are reconfigured (overrides are not applied again), new brokers are built,
+// and the routes are replaced. Brokers missing in the changed config are kept.
+// The config is checked and the new brokers and routers are built before anything is changed, so the topology
+// is left unchanged on error. Only a broker closed concurrently can make the config apply partially.
+// Returns ErrInvalidConfig if the config refers to unknown brokers or predicates or configures negative values,
+// ErrClosed if a broker is closed, or ErrTimeout on timeout.
+func (topology *Topology[T]) ApplyConfig(config Config) error {
+	topology.mutex.Lock()
+	defer topology.mutex.Unlock()
+
+	// kept brokers can still be referred to by routes
+	builder := topology.builder
+	builder.config = Config{Brokers: make(map[string]BrokerConfig), Routes: config.Routes}
+	for name := range topology.brokers {
+		builder.config.Brokers[name] = BrokerConfig{}
+	}
+	for name, brokerConfig := range config.Brokers {
+		builder.config.Brokers[name] = brokerConfig
+	}
+	if err := builder.validate(); err != nil {
+		return err
+	}
+	for name := range config.Brokers {
+		if broker, ok := topology.brokers[name]; ok && broker.closed.Load() {
+			return broker.error(ErrClosed)
+		}
+	}
+
+	brokers := make(map[string]*Broker[T], len(builder.config.Brokers))
+	var built []*Broker[T]
+	for name := range builder.config.Brokers {
+		broker, ok := topology.brokers[name]
+		if !ok {
+			broker = builder.buildBroker(name, builder.config.Brokers[name])
+			built = append(built, broker)
+		}
+		brokers[name] = broker
+	}
+	routers, err := topology.newRouters(brokers, config.Routes)
+	if err != nil {
+		for _, broker := range built {
+			broker.close(ErrClosed)
+		}
+		return err
+	}
+
+	for name, brokerConfig := range config.Brokers {
+		if broker, ok := topology.brokers[name]; ok {
+			if err := broker.ApplyConfig(brokerConfig); err != nil {
+				return err
+			}
+		}
+	}
+	topology.builder = builder
+	topology.brokers = brokers
+	for from, router := range routers {
+		topology.routers[from] = router
+	}
+	topology.replaceRoutes(config.Routes)
+	return nil
+}
+
+// newRouters creates the routers for the source brokers of the routes that have no router yet.
+// Closes the created routers again if a router cannot be created.
+func (topology *Topology[T]) newRouters(brokers map[string]*Broker[T], configs []RouteConfig) (map[string]*Router[T], error) {
+	routers := make(map[string]*Router[T])
+	for _, routeConfig := range configs {
+		if _, ok := topology.routers[routeConfig.From]; ok {
+			continue
+		}
+		if _, ok := routers[routeConfig.From]; ok {
+			continue
+		}
+		router, err := NewRouter(brokers[routeConfig.From])
+		if err != nil {
+			for _, router := range routers {
+				_ = router.Close()
+			}
+			return nil, err
+		}
+		routers[routeConfig.From] = router
+	}
+	return routers, nil
+}
+
+// replaceRoutes replaces the routes of all routers.
+func (topology *Topology[T]) replaceRoutes(routeConfigs []RouteConfig) {
+	routes := make(map[string][]route[T])
+	for _, routeConfig := range routeConfigs {
+		predicate := topology.builder.predicates[routeConfig.Predicate]
+		if predicate == nil {
+			predicate = func(T) bool { return true }
+		}
+		routes[routeConfig.From] = append(routes[routeConfig.From], route[T]{predicate, routeConfig.To})
+	}
+	for from, router := range topology.routers {
+		router.replaceRoutes(topology.brokers, routes[from])
+	}
+}
+
+// Broker returns the named broker of the topology, or nil if there is no broker with that name.
+func (topology *Topology[T]) Broker(name string) *Broker[T] {
+	topology.mutex.Lock()
+	defer topology.mutex.Unlock()
+	return topology.brokers[name]
+}
+
+// Close closes all routers and brokers of the topology. Brokers that were closed by their user already are skipped.
+// Returns the first error returned when closing a router.
+func (topology *Topology[T]) Close() error {
+	topology.mutex.Lock()
+	defer topology.mutex.Unlock()
+	var err error
+	for _, router := range topology.routers {
+		if closeErr := router.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	for _, broker := range topology.brokers {
+		broker.close(ErrClosed)
+	}
+	return err
+}
@@ -0,0 +1,298 @@
+package broker
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `{
+	"brokers": {
+		"input": {"timeout": "100ms", "bufferSize": 0},
+		"even": {"timeout": "200ms"},
+		"all": {}
+	},
+	"routes": [
+		{"from": "input", "predicate": "even", "to": ["even"]},
+		{"from": "input", "to": ["all"]}
+	]
+}`
+
+func TestLoadConfig(t *testing.T) {
+	assertions := assert.New(t)
+
+	config, err := LoadConfig(strings.NewReader(testConfig))
+	assertions.Nil(err)
+
+	bufferSize := 0
+	assertions.Equal(Config{
+		Brokers: map[string]BrokerConfig{
+			"input": {Timeout: Duration(100 * time.Millisecond), BufferSize: &bufferSize},
+			"even":  {Timeout: Duration(200 * time.Millisecond)},
+			"all":   {},
+		},
+		Routes: []RouteConfig{
+			{From: "input", Predicate: "even", To: []string{"even"}},
+			{From: "input", To: []string{"all"}},
+		},
+	}, config)
+
+	_, err = LoadConfig(strings.NewReader(`{"brokers": {"input": {"timeout": "invalid"}}}`))
+	assertions.Error(err)
+	_, err = LoadConfig(strings.NewReader(`{"unknown": {}}`))
+	assertions.Error(err)
+}
+
+func TestDuration(t *testing.T) {
+	assertions := assert.New(t)
+
+	text, err := json.Marshal(Duration(90 * time.Second))
+	assertions.Nil(err)
+	assertions.Equal(`"1m30s"`, string(text))
+
+	var duration Duration
+	assertions.Nil(json.Unmarshal(text, &duration))
+	assertions.Equal(Duration(90*time.Second), duration)
+}
+
+func TestTopology(t *testing.T) {
+	assertions := assert.New(t)
+
+	config, err := LoadConfig(strings.NewReader(testConfig))
+	assertions.Nil(err)
+
+	topology, err := NewTopologyBuilder[int](config).
+		Predicate("even", func(msg int) bool { return msg%2 == 0 }).
+		Override("all", func(builder Builder[int]) Builder[int] { return builder.BufferSize(5) }).
+		Build()
+	assertions.Nil(err)
+
+	input, even, all := topology.Broker("input"), topology.Broker("even"), topology.Broker("all")
+	assertions.Nil(topology.Broker("unknown"))
+	assertions.Equal(100*time.Millisecond, input.currentTimeout())
+	assertions.Equal(0, input.bufferSize())
+	assertions.Equal(200*time.Millisecond, even.currentTimeout())
+	assertions.Equal(defaultBufferSize, even.bufferSize())
+	assertions.Equal(defaultTimeout, all.currentTimeout())
+	assertions.Equal(5, all.bufferSize())
+
+	evenClient, err := even.Subscribe()
+	assertions.Nil(err)
+	allClient, err := all.Subscribe()
+	assertions.Nil(err)
+
+	assertions.Nil(input.Publish(1))
+	assertions.Nil(input.Publish(2))
+	assertions.Equal(1, <-allClient)
+	assertions.Equal(2, <-evenClient)
+	assertions.Equal(2, <-allClient)
+
+	// brokers closed by their user are skipped
+	topology.Broker("all").Close()
+	assertions.NotPanics(func() { assertions.Nil(topology.Close()) })
+	_, ok := <-evenClient
+	assertions.False(ok)
+}
+
+func TestTopologyInvalidConfig(t *testing.T) {
+	assertions := assert.New(t)
+
+	config := Config{
+		Brokers: map[string]BrokerConfig{"input": {}},
+		Routes:  []RouteConfig{{From: "input", To: []string{"unknown"}}},
+	}
+	_, err := NewTopologyBuilder[int](config).Build()
+	assertions.ErrorIs(err, ErrInvalidConfig)
+	assertions.ErrorContains(err, `unknown broker "unknown"`)
+
+	config.Routes = []RouteConfig{{From: "input", Predicate: "unknown", To: []string{"input"}}}
+	_, err = NewTopologyBuilder[int](config).Build()
+	assertions.ErrorIs(err, ErrInvalidConfig)
+	assertions.ErrorContains(err, `unknown predicate "unknown"`)
+
+	config.Routes = nil
+	_, err = NewTopologyBuilder[int](config).Override("unknown", nil).Build()
+	assertions.ErrorIs(err, ErrInvalidConfig)
+	assertions.ErrorContains(err, `override of unknown broker "unknown"`)
+
+	bufferSize := -1
+	config.Brokers["input"] = BrokerConfig{BufferSize: &bufferSize}
+	_, err = NewTopologyBuilder[int](config).Build()
+	assertions.ErrorIs(err, ErrInvalidConfig)
+	assertions.ErrorContains(err, `broker "input": invalid config: negative buffer size -1`)
+}
+
+func TestBrokerApplyConfig(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().BufferSize(2).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	received := make(chan []int)
+	go func() {
+		var messages []int
+		for msg := range client {
+			messages = append(messages, msg)
+		}
+		received <- messages
+	}()
+
+	// publish messages to the buffer before it is resized
+	for msg := 0; msg < 3; msg++ {
+		assertions.Nil(broker.Publish(msg))
+	}
+
+	bufferSize := 5
+	assertions.Nil(broker.ApplyConfig(BrokerConfig{Timeout: Duration(100 * time.Millisecond), BufferSize: &bufferSize}))
+	assertions.Nil(broker.ApplyConfig(BrokerConfig{BufferSize: &bufferSize}))
+	assertions.Equal(100*time.Millisecond, broker.currentTimeout())
+	assertions.Equal(bufferSize, broker.bufferSize())
+	negative := -1
+	assertions.ErrorIs(broker.ApplyConfig(BrokerConfig{BufferSize: &negative}), ErrInvalidConfig)
+	assertions.ErrorIs(broker.ApplyConfig(BrokerConfig{Timeout: Duration(-time.Second)}), ErrInvalidConfig)
+	assertions.Equal(100*time.Millisecond, broker.currentTimeout())
+
+	for msg := 3; msg < 8; msg++ {
+		assertions.Nil(broker.Publish(msg))
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	broker.Close()
+	assertions.Equal([]int{0, 1, 2, 3, 4, 5, 6, 7}, <-received)
+	assertions.ErrorIs(broker.ApplyConfig(BrokerConfig{}), ErrClosed)
+}
+
+func TestBrokerApplyConfigBlockedPublisher(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().BufferSize(1).Timeout(time.Second).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// the broker blocks delivering the first message, the second message fills the buffer
+	assertions.Nil(broker.Publish(0))
+	assertions.Nil(broker.Publish(1))
+	published := make(chan error)
+	go func() {
+		published <- broker.Publish(2)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// the blocked publisher neither delays resizing the buffer nor publishing to the resized buffer
+	bufferSize := 2
+	start := time.Now()
+	assertions.Nil(broker.ApplyConfig(BrokerConfig{BufferSize: &bufferSize}))
+	assertions.Nil(broker.TryPublish(3))
+	assertions.Less(time.Since(start), 100*time.Millisecond)
+	assertions.Equal(bufferSize, broker.bufferSize())
+
+	received := make([]int, 0, 4)
+	for i := 0; i < 4; i++ {
+		received = append(received, <-client)
+	}
+	assertions.Nil(<-published)
+	assertions.Equal([]int{0, 1}, received[:2])
+	assertions.ElementsMatch([]int{2, 3}, received[2:])
+	broker.Close()
+}
+
+func TestTopologyApplyConfig(t *testing.T) {
+	assertions := assert.New(t)
+
+	config, err := LoadConfig(strings.NewReader(testConfig))
+	assertions.Nil(err)
+
+	topology, err := NewTopologyBuilder[int](config).
+		Predicate("even", func(msg int) bool { return msg%2 == 0 }).
+		Predicate("odd", func(msg int) bool { return msg%2 != 0 }).
+		Build()
+	assertions.Nil(err)
+
+	input, even, all := topology.Broker("input"), topology.Broker("even"), topology.Broker("all")
+	evenClient, err := even.Subscribe()
+	assertions.Nil(err)
+	allClient, err := all.Subscribe()
+	assertions.Nil(err)
+
+	changed := Config{
+		Brokers: map[string]BrokerConfig{
+			"input": {Timeout: Duration(300 * time.Millisecond)},
+			"odd":   {},
+		},
+		Routes: []RouteConfig{
+			{From: "input", Predicate: "odd", To: []string{"odd", "even"}},
+			{From: "even", To: []string{"all"}},
+		},
+	}
+	assertions.Nil(topology.ApplyConfig(changed))
+	assertions.Len(changed.Brokers, 2)
+	assertions.Equal(300*time.Millisecond, input.currentTimeout())
+	assertions.Same(even, topology.Broker("even"))
+
+	oddClient, err := topology.Broker("odd").Subscribe()
+	assertions.Nil(err)
+
+	assertions.Nil(input.Publish(1))
+	assertions.Nil(input.Publish(2))
+	assertions.Equal(1, <-oddClient)
+	assertions.Equal(1, <-evenClient)
+	assertions.Equal(1, <-allClient)
+
+	select {
+	case <-evenClient:
+		assertions.Fail("Received message not expected")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	changed.Routes = []RouteConfig{{From: "input", Predicate: "unknown", To: []string{"odd"}}}
+	assertions.ErrorIs(topology.ApplyConfig(changed), ErrInvalidConfig)
+
+	assertions.Nil(topology.Close())
+}
+
+func TestTopologyApplyConfigUnchangedOnError(t *testing.T) {
+	assertions := assert.New(t)
+
+	config, err := LoadConfig(strings.NewReader(testConfig))
+	assertions.Nil(err)
+
+	topology, err := NewTopologyBuilder[int](config).
+		Predicate("even", func(msg int) bool { return msg%2 == 0 }).
+		Build()
+	assertions.Nil(err)
+
+	input, all := topology.Broker("input"), topology.Broker("all")
+	allClient, err := all.Subscribe()
+	assertions.Nil(err)
+
+	// the route from the closed broker fails, which must not change the timeout of the input broker
+	even := topology.Broker("even")
+	even.Close()
+	changed := Config{
+		Brokers: map[string]BrokerConfig{
+			"input": {Timeout: Duration(300 * time.Millisecond)},
+			"odd":   {},
+		},
+		Routes: []RouteConfig{
+			{From: "input", To: []string{"odd"}},
+			{From: "even", To: []string{"odd"}},
+		},
+	}
+	assertions.ErrorIs(topology.ApplyConfig(changed), ErrClosed)
+	assertions.Equal(100*time.Millisecond, input.currentTimeout())
+	assertions.Nil(topology.Broker("odd"))
+
+	// the previous routes are kept
+	assertions.Nil(input.Publish(1))
+	assertions.Equal(1, <-allClient)
+
+	changed.Brokers = map[string]BrokerConfig{"even": {}}
+	changed.Routes = nil
+	assertions.ErrorIs(topology.ApplyConfig(changed), ErrClosed)
+
+	assertions.Nil(topology.Close())
+}
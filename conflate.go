@@ -0,0 +1,49 @@
+package broker
+
+import "container/heap"
+
+// Conflate configures the broker to keep only the latest of the pending messages with the same key:
+// a message published with a key replaces the pending message with the same key, which is discarded as suppressed
+// instead of broadcast, so that slow clients only receive the latest state per key, like the latest price of a stock.
+// The latest message takes the place of the replaced message in the order of the pending messages.
+func (builder Builder[T]) Conflate() Builder[T] {
+	builder.conflate = true
+	return builder
+}
+
+// conflate replaces the pending message with the same key as the message, and reports whether it did.
+func (broker *Broker[T]) conflate(env envelope[T]) bool {
+	if !broker.conflating || env.key == "" {
+		return false
+	}
+	for i := range broker.pending.envelopes {
+		pending := &broker.pending.envelopes[i]
+		if pending.key != env.key {
+			continue
+		}
+		replaced := *pending
+		env.sequence = replaced.sequence
+		*pending = env
+		heap.Fix(&broker.pending, i)
+		broker.suppress(&replaced)
+		return true
+	}
+	return false
+}
+
+// suppress discards a message that was replaced by a later message.
+func (broker *Broker[T]) suppress(env *envelope[T]) {
+	broker.untrack(env)
+	broker.acknowledge(env)
+	if env.result != nil {
+		env.result <- nil
+	}
+	if env.outcome != nil {
+		env.outcome <- Outcome{Suppressed: true}
+	}
+	if broker.tracer != nil {
+		trace := env.trace()
+		trace.Suppressed = true
+		broker.tracer(*trace)
+	}
+}
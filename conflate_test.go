@@ -0,0 +1,40 @@
+package broker
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConflate(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[string]().Timeout(time.Second).Conflate().Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// the broker waits for the client to receive the first message, while the other messages are pending
+	assertions.Nil(broker.PublishWithOptions("first"))
+	assertions.Eventually(func() bool {
+		return broker.MemoryUsage().BufferedMessages == 0
+	}, time.Second, time.Millisecond)
+	var outcomes []<-chan Outcome
+	for i := 1; i <= 3; i++ {
+		outcomes = append(outcomes, broker.PublishAsync("a"+strconv.Itoa(i), WithKey("a")))
+		time.Sleep(10 * time.Millisecond)
+		assertions.Nil(broker.PublishWithOptions("b"+strconv.Itoa(i), WithKey("b")))
+	}
+	assertions.Nil(broker.PublishWithOptions("unkeyed"))
+
+	assertions.Equal(
+		[]string{"first", "a3", "b3", "unkeyed"},
+		[]string{<-client, <-client, <-client, <-client},
+	)
+	assertions.True((<-outcomes[0]).Suppressed)
+	assertions.True((<-outcomes[1]).Suppressed)
+	assertions.Equal(1, (<-outcomes[2]).Delivered)
+
+	broker.Close()
+}
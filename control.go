@@ -0,0 +1,58 @@
+package broker
+
+// subscribe adds a new client to the broker.
+// A client subscribed with replay is sent the latest broadcast messages first.
+// A client subscribed with catch up during a broadcast is sent the message being broadcast.
+// The messages held after recovering a write-ahead log are released once enough clients subscribed.
+func (broker *Broker[T]) subscribe(registration registration[T]) {
+	if registration.subscriber.replay > 0 {
+		broker.replay(registration.client, registration.subscriber.replay)
+	}
+	broker.clients[registration.client] = registration.subscriber
+	broker.join(registration.client, registration.subscriber)
+	broker.trackClient(registration.client)
+	broker.counters.subscribers.Store(int64(len(broker.clients)))
+	if registration.subscriber.catchUp && broker.broadcasting != nil {
+		broker.joined = append(broker.joined, registration.client)
+	}
+	broker.release()
+}
+
+// unsubscribe removes a client from the broker and closes it, and confirms the removal.
+// A client that is not subscribed anymore, like an evicted client, was closed already and is left alone.
+func (broker *Broker[T]) unsubscribe(unsubscription unsubscription[T]) {
+	if sub, ok := broker.clients[unsubscription.client]; ok {
+		broker.leave(unsubscription.client, sub)
+		delete(broker.clients, unsubscription.client)
+		broker.untrackClient(unsubscription.client)
+		close(unsubscription.client)
+		broker.counters.subscribers.Store(int64(len(broker.clients)))
+	}
+	close(unsubscription.done)
+}
+
+// updateFilter replaces the filter of a client.
+func (broker *Broker[T]) updateFilter(update filterUpdate[T]) {
+	if sub, ok := broker.clients[update.client]; ok {
+		sub.filter = update.filter
+	}
+}
+
+// grant adds credits to a client subscribed with credits.
+func (broker *Broker[T]) grant(grant grant[T]) {
+	if sub, ok := broker.clients[grant.client]; ok && sub.credited {
+		sub.credits += grant.credits
+	}
+}
+
+// reportStatus replies the status of a client, or nil if it is not subscribed.
+func (broker *Broker[T]) reportStatus(request statusRequest[T]) {
+	if sub, ok := broker.clients[request.client]; ok {
+		request.reply <- sub.status(request.client)
+	} else if status, ok := broker.evicted[request.client]; ok {
+		delete(broker.evicted, request.client)
+		request.reply <- status
+	} else {
+		request.reply <- nil
+	}
+}
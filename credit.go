@@ -0,0 +1,49 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// grant carries credits for a client to the broker loop.
+type grant[T any] struct {
+	client  Client[T]
+	credits int
+}
+
+// SubscribeWithCredits registers a new client with flow control by credits to the broker and returns it to the caller.
+// The client starts with the prefetch credits, and the broker spends a credit on every message sent to the client.
+// The client is buffered by the prefetch size, so that the broker does not wait for the client while it has credits.
+// Without credits left, the client drops messages: messages broadcast until it grants new credits after processing
+// are not held for the client, but counted as dropped in its status and never sent to it.
+// Returns ErrInvalidConfig if the prefetch credits are negative, ErrTimeout on timeout,
+// or ErrClosed if the broker is closed.
+func (broker *Broker[T]) SubscribeWithCredits(prefetch int) (Client[T], error) {
+	if prefetch < 0 {
+		return nil, broker.error(fmt.Errorf("%w: negative prefetch %d", ErrInvalidConfig, prefetch))
+	}
+	client := make(Client[T], prefetch)
+	if err := broker.register(context.Background(), client, &subscriber[T]{credited: true, credits: prefetch}); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Grant adds credits to a client subscribed with credits, allowing the broker to send as many more messages to it.
+// Has no effect if the credits are not positive, or if the client is not subscribed to the broker with credits.
+// Returns ErrTimeout on timeout, or ErrClosed if the broker is closed.
+func (broker *Broker[T]) Grant(client Client[T], credits int) error {
+	if credits <= 0 {
+		// negative credits would disable the flow control of the client
+		return nil
+	}
+	select {
+	case broker.grants <- grant[T]{client, credits}:
+		return nil
+	case <-time.After(broker.currentTimeout()):
+		return broker.error(ErrTimeout)
+	case <-broker.stop:
+		return broker.error(ErrClosed)
+	}
+}
@@ -0,0 +1,55 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribeWithCredits(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+	client, err := broker.SubscribeWithCredits(2)
+	assertions.Nil(err)
+	assertions.Equal(2, cap(client))
+
+	// the broker does not wait for the client while it has credits, and discards messages without credits
+	for msg := 1; msg <= 3; msg++ {
+		assertions.Nil(broker.Publish(msg))
+	}
+	assertions.Eventually(func() bool {
+		return broker.Stats().Broadcasts == 3
+	}, time.Second, 10*time.Millisecond)
+	status, err := broker.SubscriberStatus(client)
+	assertions.Nil(err)
+	assertions.Equal(uint64(2), status.Delivered)
+	assertions.Equal(uint64(1), status.Dropped)
+	assertions.Equal(0, status.Credits)
+	assertions.Equal(1, <-client)
+	assertions.Equal(2, <-client)
+
+	assertions.Nil(broker.Grant(client, 1))
+	assertions.Nil(broker.Publish(4))
+	assertions.Equal(4, <-client)
+
+	// granting non-positive credits has no effect
+	assertions.Nil(broker.Grant(client, -5))
+	status, err = broker.SubscriberStatus(client)
+	assertions.Nil(err)
+	assertions.Equal(0, status.Credits)
+
+	// granting credits to a client subscribed without credits has no effect
+	other, err := broker.Subscribe()
+	assertions.Nil(err)
+	assertions.Nil(broker.Grant(other, 1))
+	status, err = broker.SubscriberStatus(other)
+	assertions.Nil(err)
+	assertions.Equal(0, status.Credits)
+
+	_, err = broker.SubscribeWithCredits(-1)
+	assertions.ErrorIs(err, ErrInvalidConfig)
+
+	broker.Close()
+}
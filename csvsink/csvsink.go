@@ -0,0 +1,164 @@
+// Package csvsink provides a sink that flattens broker messages into CSV rows written to an io.Writer.
+package csvsink
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+// Builder encapsulates the construction and configuration of a CSV sink.
+type Builder[T any] struct {
+	header        []string
+	row           func(T) []string
+	flushInterval time.Duration
+	comma         rune
+}
+
+// defaultFlushInterval specifies the default interval in which written rows are flushed to the writer.
+const defaultFlushInterval = time.Second
+
+// ErrUnsupportedType is the error returned when rows are derived from struct tags, but the message type is no struct.
+var ErrUnsupportedType = errors.New("csvsink: message type is no struct")
+
+// NewBuilder constructs a new builder.
+// By default, rows and header are derived from the exported fields of the message struct type,
+// which are named by their `csv` struct tag (or their field name), and skipped if the tag is "-".
+func NewBuilder[T any]() Builder[T] {
+	return Builder[T]{flushInterval: defaultFlushInterval, comma: ','}
+}
+
+// Header configures the header row, which is written before all other rows.
+// An empty header disables the header row.
+func (builder Builder[T]) Header(header ...string) Builder[T] {
+	builder.header = header
+	if builder.header == nil {
+		builder.header = []string{}
+	}
+	return builder
+}
+
+// Row configures the function that flattens a message into a row.
+func (builder Builder[T]) Row(row func(T) []string) Builder[T] {
+	builder.row = row
+	return builder
+}
+
+// FlushInterval configures the interval in which written rows are flushed to the writer.
+// If the interval is not positive, every row is flushed immediately.
+func (builder Builder[T]) FlushInterval(flushInterval time.Duration) Builder[T] {
+	builder.flushInterval = flushInterval
+	return builder
+}
+
+// Comma configures the field delimiter.
+func (builder Builder[T]) Comma(comma rune) Builder[T] {
+	builder.comma = comma
+	return builder
+}
+
+// Sink writes every message received by the client as a CSV row to the writer, until the client is closed.
+// Returns ErrUnsupportedType if no row function is configured and the message type is no struct,
+// or the first error encountered when writing a row, the client stays subscribed then.
+func (builder Builder[T]) Sink(writer io.Writer, client <-chan T) error {
+	row, header := builder.row, builder.header
+	if row == nil {
+		fields, err := structFields[T]()
+		if err != nil {
+			return err
+		}
+		row = fields.row
+		if header == nil {
+			header = fields.names
+		}
+	}
+
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Comma = builder.comma
+	if len(header) > 0 {
+		if err := csvWriter.Write(header); err != nil {
+			return err
+		}
+	}
+
+	var tick <-chan time.Time
+	if builder.flushInterval > 0 {
+		ticker := time.NewTicker(builder.flushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case message, ok := <-client:
+			if !ok {
+				csvWriter.Flush()
+				return csvWriter.Error()
+			}
+			if err := csvWriter.Write(row(message)); err != nil {
+				return err
+			}
+			if tick == nil {
+				csvWriter.Flush()
+			}
+		case <-tick:
+			csvWriter.Flush()
+		}
+		if err := csvWriter.Error(); err != nil {
+			return err
+		}
+	}
+}
+
+// fields describes the exported fields of a struct type that are flattened into rows.
+type fields[T any] struct {
+	names   []string
+	indexes [][]int
+}
+
+// structFields derives the fields from the struct tags of the message type.
+func structFields[T any]() (*fields[T], error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, ErrUnsupportedType
+	}
+	result := &fields[T]{}
+	for _, field := range reflect.VisibleFields(typ) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("csv"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		result.names = append(result.names, name)
+		result.indexes = append(result.indexes, field.Index)
+	}
+	return result, nil
+}
+
+// row flattens a message into a row, using an empty value for fields of nil embedded structs or a nil message.
+func (fields *fields[T]) row(message T) []string {
+	row := make([]string, len(fields.indexes))
+	value := reflect.ValueOf(&message).Elem()
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return row
+		}
+		value = value.Elem()
+	}
+	for i, index := range fields.indexes {
+		if field, err := value.FieldByIndexErr(index); err == nil {
+			row[i] = fmt.Sprint(field.Interface())
+		}
+	}
+	return row
+}
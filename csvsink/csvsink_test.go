@@ -0,0 +1,101 @@
+package csvsink
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mpe85/go-broker"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+type Base struct {
+	ID int `csv:"id"`
+}
+
+type trade struct {
+	Base
+	Symbol string  `csv:"symbol"`
+	Price  float64 `csv:"price"`
+	Volume int
+	Note   string `csv:"-"`
+	secret string
+}
+
+func sink[T any](builder Builder[T], messages ...T) (string, error) {
+	theBroker := broker.NewBuilder[T]().Timeout(100 * time.Millisecond).Build()
+	client, err := theBroker.Subscribe()
+	if err != nil {
+		return "", err
+	}
+	var buffer bytes.Buffer
+	done := make(chan error)
+	go func() {
+		done <- builder.Sink(&buffer, client)
+	}()
+	for _, message := range messages {
+		if err := theBroker.Publish(message); err != nil {
+			return "", err
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	theBroker.Close()
+	err = <-done
+	return buffer.String(), err
+}
+
+func TestNewBuilder(t *testing.T) {
+	assertions := assert.New(t)
+
+	builder := NewBuilder[trade]()
+	assertions.Nil(builder.header)
+	assertions.Nil(builder.row)
+	assertions.Equal(defaultFlushInterval, builder.flushInterval)
+	assertions.Equal(',', builder.comma)
+}
+
+func TestSinkStructTags(t *testing.T) {
+	assertions := assert.New(t)
+
+	output, err := sink(NewBuilder[trade](),
+		trade{Base{1}, "ABC", 1.5, 100, "note", "secret"},
+		trade{Base{2}, "X,Y", 2, 200, "note", "secret"})
+	assertions.Nil(err)
+	assertions.Equal("id,symbol,price,Volume\n1,ABC,1.5,100\n2,\"X,Y\",2,200\n", output)
+}
+
+func TestSinkPointerStructTags(t *testing.T) {
+	assertions := assert.New(t)
+
+	output, err := sink(NewBuilder[*trade]().Header().Comma(';').FlushInterval(0),
+		&trade{Base{1}, "ABC", 1.5, 100, "", ""}, nil)
+	assertions.Nil(err)
+	assertions.Equal("1;ABC;1.5;100\n;;;\n", output)
+}
+
+func TestSinkRow(t *testing.T) {
+	assertions := assert.New(t)
+
+	builder := NewBuilder[int]().
+		Header("value", "square").
+		Row(func(value int) []string {
+			return []string{strconv.Itoa(value), strconv.Itoa(value * value)}
+		}).
+		FlushInterval(10 * time.Millisecond)
+	output, err := sink(builder, 2, 3)
+	assertions.Nil(err)
+	assertions.Equal("value,square\n2,4\n3,9\n", output)
+}
+
+func TestSinkUnsupportedType(t *testing.T) {
+	assertions := assert.New(t)
+
+	_, err := sink(NewBuilder[int]())
+	assertions.ErrorIs(err, ErrUnsupportedType)
+}
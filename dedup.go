@@ -0,0 +1,77 @@
+package broker
+
+import "time"
+
+// dedup remembers the identities of the messages broadcast within a window, to suppress duplicates of them.
+type dedup[T any] struct {
+	window   time.Duration
+	identify func(T) (any, bool)
+	seen     map[any]time.Time
+	order    []dedupEntry
+}
+
+// dedupEntry is an identity remembered by the dedup window, in the order they were remembered.
+type dedupEntry struct {
+	identity  any
+	published time.Time
+}
+
+// WithIdempotencyKey configures the idempotency key of a message. If the broker has a dedup window,
+// the message is suppressed if a message with the same idempotency key was published within the window before.
+func WithIdempotencyKey(key string) PublishOption {
+	return func(options *publishOptions) {
+		options.idempotencyKey = key
+	}
+}
+
+// DedupWindow configures the broker to suppress messages with the same idempotency key as a message published
+// within the window before, so that publishers can retry messages without duplicating them.
+func (builder Builder[T]) DedupWindow(window time.Duration) Builder[T] {
+	builder.dedupWindow = window
+	return builder
+}
+
+// NewDedupWindowBuilder constructs a new builder for a broker with a dedup window,
+// which suppresses messages without idempotency key that equal a message published within the window before.
+func NewDedupWindowBuilder[T comparable](window time.Duration) Builder[T] {
+	builder := NewBuilder[T]().DedupWindow(window)
+	builder.identify = func(message T) (any, bool) { return message, true }
+	return builder
+}
+
+// newDedup constructs a new dedup window, which identifies messages without idempotency key by the function (if any).
+func newDedup[T any](window time.Duration, identify func(T) (any, bool)) *dedup[T] {
+	if identify == nil {
+		identify = func(T) (any, bool) { return nil, false }
+	}
+	return &dedup[T]{window: window, identify: identify, seen: make(map[any]time.Time)}
+}
+
+// idempotencyKey distinguishes idempotency keys from messages that are strings.
+type idempotencyKey string
+
+// duplicate reports whether a message is a duplicate of a message published within the window before,
+// and remembers it otherwise.
+func (dedup *dedup[T]) duplicate(env *envelope[T]) bool {
+	// forget the identities that left the window
+	for len(dedup.order) > 0 && env.published.Sub(dedup.order[0].published) >= dedup.window {
+		entry := dedup.order[0]
+		dedup.order = dedup.order[1:]
+		if dedup.seen[entry.identity] == entry.published {
+			delete(dedup.seen, entry.identity)
+		}
+	}
+	var identity any = idempotencyKey(env.idempotencyKey)
+	if env.idempotencyKey == "" {
+		var ok bool
+		if identity, ok = dedup.identify(env.message); !ok {
+			return false
+		}
+	}
+	if published, ok := dedup.seen[identity]; ok && env.published.Sub(published) < dedup.window {
+		return true
+	}
+	dedup.seen[identity] = env.published
+	dedup.order = append(dedup.order, dedupEntry{identity, env.published})
+	return false
+}
@@ -0,0 +1,57 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupWindow(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[string]().Timeout(100 * time.Millisecond).DedupWindow(100 * time.Millisecond).Build()
+	client, err := broker.Subscribe(WithBufferSize(10))
+	assertions.Nil(err)
+
+	delivered, _, err := broker.PublishCounted("order created", WithIdempotencyKey("42"))
+	assertions.Nil(err)
+	assertions.Equal(1, delivered)
+	outcome := <-broker.PublishAsync("order created (retry)", WithIdempotencyKey("42"))
+	assertions.True(outcome.Suppressed)
+	// messages without idempotency key are not deduplicated by value
+	assertions.Nil(broker.PublishSync("order created"))
+	assertions.Nil(broker.PublishSync("order created", WithIdempotencyKey("43")))
+
+	// the key can be published again after the window passed
+	time.Sleep(150 * time.Millisecond)
+	assertions.Nil(broker.PublishSync("order created (retry)", WithIdempotencyKey("42")))
+
+	assertions.Equal(
+		[]string{"order created", "order created", "order created", "order created (retry)"},
+		[]string{<-client, <-client, <-client, <-client},
+	)
+	assertions.Zero(len(client))
+
+	broker.Close()
+}
+
+func TestNewDedupWindowBuilder(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewDedupWindowBuilder[string](time.Minute).Timeout(100 * time.Millisecond).Build()
+	client, err := broker.Subscribe(WithBufferSize(10))
+	assertions.Nil(err)
+
+	for _, msg := range []string{"a", "b", "a", "c", "b"} {
+		assertions.Nil(broker.PublishSync(msg))
+	}
+	// the idempotency key takes precedence over the value
+	assertions.Nil(broker.PublishSync("a", WithIdempotencyKey("a")))
+	assertions.Nil(broker.PublishSync("d", WithIdempotencyKey("a")))
+
+	assertions.Equal([]string{"a", "b", "c", "a"}, []string{<-client, <-client, <-client, <-client})
+	assertions.Zero(len(client))
+
+	broker.Close()
+}
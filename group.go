@@ -0,0 +1,120 @@
+package broker
+
+import "hash/fnv"
+
+// group holds the clients subscribed to the broker with the same group name, which share the messages.
+// In round-robin mode, the clients without group name form a group as well.
+type group[T any] struct {
+	members []Client[T]
+	next    int
+}
+
+// WithGroup configures a client to join a consumer group: every message is sent to only one client of the group,
+// chosen in turn among the clients whose filter (and route keys) match the message and which have credits left (if subscribed
+// with credits). This distributes the messages among the clients of a group like a work queue,
+// while clients of other groups and clients without group still receive every message.
+// Messages published with a key are partitioned by their key instead, among the clients whose filter (and route keys)
+// match the message: all messages with the same key are sent to the same client of the group in the order they were
+// broadcast, as long as the clients of the group and their filters do not change.
+func WithGroup(name string) SubscribeOption {
+	return func(options *subscribeOptions) {
+		options.group = name
+	}
+}
+
+// join adds a client to its group, if any.
+func (broker *Broker[T]) join(client Client[T], sub *subscriber[T]) {
+	if !broker.grouped(sub) {
+		return
+	}
+	if broker.groups == nil {
+		broker.groups = make(map[string]*group[T])
+	}
+	members, ok := broker.groups[sub.group]
+	if !ok {
+		members = &group[T]{}
+		broker.groups[sub.group] = members
+	}
+	members.members = append(members.members, client)
+}
+
+// leave removes a client from its group, if any, and removes the group when its last client left.
+func (broker *Broker[T]) leave(client Client[T], sub *subscriber[T]) {
+	members, ok := broker.groups[sub.group]
+	if !ok {
+		return
+	}
+	for i, member := range members.members {
+		if member == client {
+			members.members = append(members.members[:i], members.members[i+1:]...)
+			if members.next > i {
+				members.next--
+			}
+			break
+		}
+	}
+	if len(members.members) == 0 {
+		delete(broker.groups, sub.group)
+	}
+}
+
+// RoundRobin configures the broker to send every message to only one client, chosen in turn like in a group,
+// instead of broadcasting it to all clients. This distributes the messages among identical workers.
+// Clients subscribed with a group still share the messages with the other clients of their group only.
+func (builder Builder[T]) RoundRobin() Builder[T] {
+	builder.roundRobin = true
+	return builder
+}
+
+// grouped reports whether a client receives the messages as part of a group.
+func (broker *Broker[T]) grouped(sub *subscriber[T]) bool {
+	return sub.group != "" || broker.roundRobin
+}
+
+// sendToGroups sends a message to the next client of each group that accepts it,
+// and records the delivery in the trace (if not nil).
+// A message with a key is sent to the client of each group the key is partitioned to instead.
+func (broker *Broker[T]) sendToGroups(env *envelope[T], trace *Trace[T]) {
+	for _, members := range broker.groups {
+		if env.key != "" {
+			broker.sendToPartition(members, env, trace)
+			continue
+		}
+		for n := 0; n < len(members.members); n++ {
+			i := (members.next + n) % len(members.members)
+			client := members.members[i]
+			sub := broker.clients[client]
+			if !sub.accepts(env) || (sub.credited && sub.credits == 0) {
+				continue
+			}
+			members.next = i + 1
+			broker.sendTo(client, sub, env, trace)
+			break
+		}
+	}
+}
+
+// sendToPartition sends a message with a key to the client of a group the key is partitioned to,
+// among the clients of the group that accept the message.
+func (broker *Broker[T]) sendToPartition(members *group[T], env *envelope[T], trace *Trace[T]) {
+	accepting := make([]Client[T], 0, len(members.members))
+	for _, client := range members.members {
+		if broker.clients[client].accepts(env) {
+			accepting = append(accepting, client)
+		}
+	}
+	if len(accepting) == 0 {
+		return
+	}
+	client := accepting[partition(env.key, len(accepting))]
+	sub := broker.clients[client]
+	sub.seen = env.sequence
+	broker.transmit(client, sub, env, trace)
+}
+
+// partition returns the index of the client among n clients that a key is partitioned to.
+func partition(key string, n int) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	return int(hash.Sum32() % uint32(n))
+}
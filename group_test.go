@@ -0,0 +1,172 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithGroup(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	worker1, err := broker.Subscribe(WithGroup("workers"), WithBufferSize(10))
+	assertions.Nil(err)
+	worker2, err := broker.Subscribe(WithGroup("workers"), WithBufferSize(10))
+	assertions.Nil(err)
+	auditor, err := broker.Subscribe(WithBufferSize(10))
+	assertions.Nil(err)
+
+	for msg := 1; msg <= 4; msg++ {
+		assertions.Nil(broker.PublishSync(msg))
+	}
+	assertions.Equal(2, len(worker1))
+	assertions.Equal(2, len(worker2))
+	received := []int{<-worker1, <-worker1, <-worker2, <-worker2}
+	assertions.ElementsMatch([]int{1, 2, 3, 4}, received)
+	assertions.Equal([]int{1, 2, 3, 4}, []int{<-auditor, <-auditor, <-auditor, <-auditor})
+
+	// the remaining client of the group receives all messages after the other client left
+	assertions.Nil(broker.Unsubscribe(worker1))
+	assertions.Nil(broker.PublishSync(5))
+	assertions.Nil(broker.PublishSync(6))
+	assertions.Equal([]int{5, 6}, []int{<-worker2, <-worker2})
+
+	broker.Close()
+}
+
+func TestWithGroupFilter(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	even, err := broker.Subscribe(WithGroup("workers"), WithBufferSize(10))
+	assertions.Nil(err)
+	assertions.Nil(broker.SetFilter(even, func(msg int) bool { return msg%2 == 0 }))
+	other, err := broker.Subscribe(WithGroup("workers"), WithBufferSize(10))
+	assertions.Nil(err)
+
+	// odd messages are only accepted by one client of the group
+	for _, msg := range []int{1, 3, 5} {
+		assertions.Nil(broker.PublishSync(msg))
+	}
+	assertions.Equal(0, len(even))
+	assertions.Equal([]int{1, 3, 5}, []int{<-other, <-other, <-other})
+
+	broker.Close()
+}
+
+func TestWithGroupKeyFilter(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	filtering, err := broker.Subscribe(WithGroup("workers"), WithBufferSize(16))
+	assertions.Nil(err)
+	assertions.Nil(broker.SetFilter(filtering, func(int) bool { return false }))
+	other, err := broker.Subscribe(WithGroup("workers"), WithBufferSize(16))
+	assertions.Nil(err)
+
+	// keyed messages are partitioned among the clients whose filter matches the message only
+	for msg := 0; msg < 8; msg++ {
+		assertions.Nil(broker.PublishWithOptions(msg, WithKey(string(rune('a'+msg)))))
+	}
+	assertions.Eventually(func() bool {
+		return broker.Stats().Broadcasts == 8
+	}, time.Second, 10*time.Millisecond)
+	assertions.Zero(len(filtering))
+	for msg := 0; msg < 8; msg++ {
+		assertions.Equal(msg, <-other)
+	}
+
+	// the messages of a key stay with the same client while the filters match
+	assertions.Nil(broker.SetFilter(filtering, func(msg int) bool { return msg%2 == 0 }))
+	for msg := 0; msg < 8; msg++ {
+		assertions.Nil(broker.PublishWithOptions(msg*2, WithKey("even")))
+		assertions.Nil(broker.PublishWithOptions(msg*2+1, WithKey("odd")))
+	}
+	assertions.Eventually(func() bool {
+		return broker.Stats().Broadcasts == 24
+	}, time.Second, 10*time.Millisecond)
+	var evenToFiltering, evenToOther, odd int
+	for len(filtering) > 0 {
+		assertions.Zero(<-filtering % 2)
+		evenToFiltering++
+	}
+	for len(other) > 0 {
+		if <-other%2 == 0 {
+			evenToOther++
+		} else {
+			odd++
+		}
+	}
+	assertions.Equal(8, odd)
+	assertions.Equal(8, evenToFiltering+evenToOther)
+	assertions.Zero(evenToFiltering * evenToOther)
+
+	broker.Close()
+}
+
+func TestRoundRobin(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).RoundRobin().Build()
+	worker1, err := broker.Subscribe(WithBufferSize(10))
+	assertions.Nil(err)
+	worker2, err := broker.Subscribe(WithBufferSize(10))
+	assertions.Nil(err)
+	auditor, err := broker.Subscribe(WithGroup("auditors"), WithBufferSize(10))
+	assertions.Nil(err)
+
+	for msg := 1; msg <= 4; msg++ {
+		assertions.Nil(broker.PublishSync(msg))
+	}
+	assertions.Equal(2, len(worker1))
+	assertions.Equal(2, len(worker2))
+	assertions.Equal([]int{1, 2, 3, 4}, []int{<-auditor, <-auditor, <-auditor, <-auditor})
+
+	broker.Close()
+}
+
+func TestWithGroupKey(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	workers := make([]Client[int], 3)
+	for i := range workers {
+		worker, err := broker.Subscribe(WithGroup("workers"), WithBufferSize(20))
+		assertions.Nil(err)
+		workers[i] = worker
+	}
+
+	for msg := 0; msg < 20; msg++ {
+		assertions.Nil(broker.PublishWithOptions(msg, WithKey(string(rune('a'+msg%4)))))
+	}
+	assertions.Nil(broker.PublishSync(20))
+
+	// every key is partitioned to one worker, which receives the messages with the key in order
+	owners := make(map[int]int)
+	received := 0
+	for i, worker := range workers {
+		last := make(map[int]int)
+		for len(worker) > 0 {
+			msg := <-worker
+			received++
+			if msg == 20 {
+				continue
+			}
+			key := msg % 4
+			if owner, ok := owners[key]; ok {
+				assertions.Equal(owner, i)
+			}
+			owners[key] = i
+			if previous, ok := last[key]; ok {
+				assertions.Less(previous, msg)
+			}
+			last[key] = msg
+		}
+	}
+	assertions.Equal(21, received)
+	assertions.Len(owners, 4)
+
+	broker.Close()
+}
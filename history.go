@@ -0,0 +1,85 @@
+package broker
+
+// history holds the last broadcast messages of a broker in a ring buffer.
+type history[T any] struct {
+	messages []T
+	sizes    []int
+	next     int
+	full     bool
+}
+
+// newHistory constructs a new history holding up to size messages.
+func newHistory[T any](size int) *history[T] {
+	return &history[T]{messages: make([]T, size), sizes: make([]int, size)}
+}
+
+// add adds a message with its estimated size to the history, replacing the oldest message if the history is full.
+func (history *history[T]) add(message T, size int) {
+	history.messages[history.next] = message
+	history.sizes[history.next] = size
+	history.next++
+	if history.next == len(history.messages) {
+		history.next, history.full = 0, true
+	}
+}
+
+// last returns up to n of the latest messages in the history, from the oldest to the latest.
+func (history *history[T]) last(n int) []T {
+	size := history.next
+	if history.full {
+		size = len(history.messages)
+	}
+	if n > size {
+		n = size
+	}
+	last := make([]T, n)
+	for i := range last {
+		last[i] = history.messages[(history.next-n+i+len(history.messages))%len(history.messages)]
+	}
+	return last
+}
+
+// remember adds a broadcast message to the history, and accounts the memory held by the history.
+func (broker *Broker[T]) remember(env *envelope[T]) {
+	if !broker.history.full {
+		broker.counters.historyMessages.Add(1)
+	}
+	broker.counters.historyBytes.Add(int64(env.size - broker.history.sizes[broker.history.next]))
+	broker.history.add(env.message, env.size)
+}
+
+// History configures the broker to keep the given number of the latest broadcast messages,
+// which are replayed to clients subscribing with ReplayLast.
+func (builder Builder[T]) History(size int) Builder[T] {
+	builder.history = size
+	return builder
+}
+
+// Retain configures the broker to retain the latest broadcast message, which is sent to every new client
+// immediately after subscribing, like the current state of a state feed. The client is buffered by one in addition.
+func (builder Builder[T]) Retain() Builder[T] {
+	builder.retain = true
+	return builder
+}
+
+// ReplayLast configures a client to receive up to n of the latest broadcast messages kept by the broker
+// immediately after subscribing, before any new messages. The client is buffered by n in addition,
+// so that the broker does not wait for the client while replaying, even if the broker keeps no history.
+func ReplayLast(n int) SubscribeOption {
+	return func(options *subscribeOptions) {
+		options.replay = n
+	}
+}
+
+// replay sends up to n of the latest broadcast messages to a new client, which has space left for them.
+func (broker *Broker[T]) replay(client Client[T], n int) {
+	if broker.history == nil {
+		return
+	}
+	for _, message := range broker.history.last(n) {
+		select {
+		case client <- message:
+		default:
+		}
+	}
+}
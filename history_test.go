@@ -0,0 +1,76 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	assertions := assert.New(t)
+
+	history := newHistory[int](3)
+	assertions.Empty(history.last(2))
+	history.add(1, 8)
+	history.add(2, 8)
+	assertions.Equal([]int{1, 2}, history.last(5))
+	assertions.Equal([]int{2}, history.last(1))
+	history.add(3, 8)
+	history.add(4, 8)
+	assertions.Equal([]int{2, 3, 4}, history.last(5))
+	assertions.Equal([]int{3, 4}, history.last(2))
+}
+
+func TestReplayLast(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().History(3).Build()
+	for msg := 1; msg <= 4; msg++ {
+		assertions.Nil(broker.Publish(msg))
+	}
+	assertions.Eventually(func() bool {
+		return broker.Stats().Broadcasts == 4
+	}, time.Second, 10*time.Millisecond)
+
+	client, err := broker.Subscribe(ReplayLast(2))
+	assertions.Nil(err)
+	assertions.Equal(2, cap(client))
+	all, err := broker.Subscribe(ReplayLast(10))
+	assertions.Nil(err)
+
+	assertions.Nil(broker.Publish(5))
+	assertions.Equal([]int{3, 4, 5}, []int{<-client, <-client, <-client})
+	assertions.Equal([]int{2, 3, 4, 5}, []int{<-all, <-all, <-all, <-all})
+
+	broker.Close()
+}
+
+func TestRetain(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[string]().Retain().Build()
+	empty, err := broker.Subscribe()
+	assertions.Nil(err)
+	assertions.Equal(1, cap(empty))
+
+	assertions.Nil(broker.Publish("20°C"))
+	assertions.Equal("20°C", <-empty)
+	assertions.Nil(broker.Publish("21°C"))
+	assertions.Equal("21°C", <-empty)
+	assertions.Eventually(func() bool {
+		return broker.Stats().Broadcasts == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// only the latest message is retained
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+	assertions.Equal("21°C", <-client)
+	select {
+	case <-client:
+		assertions.Fail("Received message not expected")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	broker.Close()
+}
@@ -0,0 +1,81 @@
+// Package jsonl provides helpers that stream broker messages as JSON Lines to an io.Writer,
+// and publish JSON Lines read from an io.Reader to a broker.
+package jsonl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mpe85/go-broker"
+)
+
+// LineError is the error describing a malformed line read by Source.
+type LineError struct {
+	// Line is the number of the malformed line, starting at 1.
+	Line int
+	// Err is the error returned when unmarshalling the line.
+	Err error
+}
+
+// Error implements error.
+func (err *LineError) Error() string {
+	return fmt.Sprintf("jsonl: malformed line %d: %v", err.Line, err.Err)
+}
+
+// Unwrap returns the error returned when unmarshalling the line.
+func (err *LineError) Unwrap() error {
+	return err.Err
+}
+
+// Sink writes every message received by the client as a JSON line to the writer, until the client is closed.
+// Returns the first error encountered when marshalling or writing a message, the client stays subscribed then.
+func Sink[T any](writer io.Writer, client <-chan T) error {
+	encoder := json.NewEncoder(writer)
+	for message := range client {
+		if err := encoder.Encode(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Source reads JSON lines from the reader and publishes each of them as message to the broker,
+// until the end of the reader is reached. Empty lines are skipped.
+// Malformed lines are passed as *LineError to the error handler, which either skips the line by returning nil,
+// or aborts reading by returning an error. If the error handler is nil, reading is aborted on the first
+// malformed line. Returns the error that aborted reading, or the first error returned when reading from
+// the reader or publishing a message (like broker.ErrTimeout).
+func Source[T any](reader io.Reader, target *broker.Broker[T], handle func(*LineError) error) error {
+	buffered := bufio.NewReader(reader)
+	for number := 1; ; number++ {
+		line, err := buffered.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			if publishErr := publish(trimmed, number, target, handle); publishErr != nil {
+				return publishErr
+			}
+		}
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+// publish unmarshals a single line and publishes it to the broker.
+func publish[T any](line []byte, number int, target *broker.Broker[T], handle func(*LineError) error) error {
+	var message T
+	if err := json.Unmarshal(line, &message); err != nil {
+		lineErr := &LineError{Line: number, Err: err}
+		if handle == nil {
+			return lineErr
+		}
+		return handle(lineErr)
+	}
+	return target.Publish(message)
+}
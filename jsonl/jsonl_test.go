@@ -0,0 +1,117 @@
+package jsonl
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpe85/go-broker"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+type event struct {
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestSink(t *testing.T) {
+	assertions := assert.New(t)
+
+	theBroker := broker.NewBuilder[event]().Timeout(100 * time.Millisecond).Build()
+	client, err := theBroker.Subscribe()
+	assertions.Nil(err)
+
+	var buffer bytes.Buffer
+	done := make(chan error)
+	go func() {
+		done <- Sink(&buffer, client)
+	}()
+
+	assertions.Nil(theBroker.Publish(event{"a", 1}))
+	assertions.Nil(theBroker.Publish(event{"b", 2}))
+	time.Sleep(100 * time.Millisecond)
+	theBroker.Close()
+
+	assertions.Nil(<-done)
+	assertions.Equal("{\"name\":\"a\",\"value\":1}\n{\"name\":\"b\",\"value\":2}\n", buffer.String())
+}
+
+func TestSinkWriteError(t *testing.T) {
+	assertions := assert.New(t)
+
+	theBroker := broker.NewBuilder[event]().Timeout(100 * time.Millisecond).Build()
+	client, err := theBroker.Subscribe()
+	assertions.Nil(err)
+
+	done := make(chan error)
+	go func() {
+		done <- Sink(failingWriter{}, client)
+	}()
+
+	assertions.Nil(theBroker.Publish(event{"a", 1}))
+	assertions.EqualError(<-done, "write failed")
+
+	theBroker.Close()
+}
+
+func TestSource(t *testing.T) {
+	assertions := assert.New(t)
+
+	theBroker := broker.NewBuilder[event]().Timeout(100 * time.Millisecond).Build()
+	client, err := theBroker.Subscribe()
+	assertions.Nil(err)
+
+	input := "{\"name\":\"a\",\"value\":1}\n\n{\"name\":\"b\",\"value\":2}"
+	assertions.Nil(Source(strings.NewReader(input), theBroker, nil))
+	assertions.Equal(event{"a", 1}, <-client)
+	assertions.Equal(event{"b", 2}, <-client)
+
+	theBroker.Close()
+}
+
+func TestSourceMalformedLine(t *testing.T) {
+	assertions := assert.New(t)
+
+	theBroker := broker.NewBuilder[event]().Timeout(100 * time.Millisecond).Build()
+	client, err := theBroker.Subscribe()
+	assertions.Nil(err)
+
+	input := "{\"name\":\"a\",\"value\":1}\nmalformed\n{\"name\":\"b\",\"value\":2}\n"
+
+	var lineErr *LineError
+	err = Source(strings.NewReader(input), theBroker, nil)
+	assertions.ErrorAs(err, &lineErr)
+	assertions.Equal(2, lineErr.Line)
+	assertions.ErrorContains(err, "jsonl: malformed line 2")
+	assertions.Equal(event{"a", 1}, <-client)
+
+	var skipped []int
+	assertions.Nil(Source(strings.NewReader(input), theBroker, func(err *LineError) error {
+		skipped = append(skipped, err.Line)
+		return nil
+	}))
+	assertions.Equal([]int{2}, skipped)
+	assertions.Equal(event{"a", 1}, <-client)
+	assertions.Equal(event{"b", 2}, <-client)
+
+	abort := errors.New("abort")
+	assertions.ErrorIs(Source(strings.NewReader(input), theBroker, func(*LineError) error {
+		return abort
+	}), abort)
+	assertions.Equal(event{"a", 1}, <-client)
+
+	theBroker.Close()
+}
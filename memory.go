@@ -0,0 +1,63 @@
+package broker
+
+// MemoryUsage is an estimate of the memory held by a broker.
+type MemoryUsage struct {
+	// BufferedMessages is the number of published messages that are not broadcast yet.
+	BufferedMessages int
+	// BufferedBytes is the estimated size in bytes of the published messages that are not broadcast yet.
+	BufferedBytes int64
+	// HistoryMessages is the number of broadcast messages kept in the history, for replay or retention.
+	HistoryMessages int
+	// HistoryBytes is the estimated size in bytes of the broadcast messages kept in the history.
+	HistoryBytes int64
+	// ClientBufferCapacity is the total capacity of the buffers of the subscribed clients.
+	ClientBufferCapacity int
+	// ClientBufferBytes is the size in bytes of the buffers of the subscribed clients, which are allocated
+	// when the clients subscribe. Memory referenced by the messages in the buffers is not included.
+	ClientBufferBytes int64
+}
+
+// MemoryUsage returns an estimate of the memory held by the broker.
+// The size of each buffered or kept message is estimated by the sizer of the broker.
+func (broker *Broker[T]) MemoryUsage() MemoryUsage {
+	capacity := broker.counters.clientCapacity.Load()
+	return MemoryUsage{
+		BufferedMessages:     int(broker.counters.bufferedMessages.Load()),
+		BufferedBytes:        broker.counters.bufferedBytes.Load(),
+		HistoryMessages:      int(broker.counters.historyMessages.Load()),
+		HistoryBytes:         broker.counters.historyBytes.Load(),
+		ClientBufferCapacity: int(capacity),
+		ClientBufferBytes:    capacity * int64(typeOf[T]().Size()),
+	}
+}
+
+// shallowSizer returns a sizer that estimates the size of a message by the size of its type,
+// not including any memory referenced by the message.
+func shallowSizer[T any]() func(T) int {
+	size := int(typeOf[T]().Size())
+	return func(T) int {
+		return size
+	}
+}
+
+// track accounts a published message as buffered.
+func (broker *Broker[T]) track(env *envelope[T]) {
+	broker.counters.bufferedMessages.Add(1)
+	broker.counters.bufferedBytes.Add(int64(env.size))
+}
+
+// trackClient accounts the buffer of a subscribed client.
+func (broker *Broker[T]) trackClient(client Client[T]) {
+	broker.counters.clientCapacity.Add(int64(cap(client)))
+}
+
+// untrackClient accounts the buffer of a client as released, when the client left the broker.
+func (broker *Broker[T]) untrackClient(client Client[T]) {
+	broker.counters.clientCapacity.Add(-int64(cap(client)))
+}
+
+// untrack accounts a published message as no longer buffered.
+func (broker *Broker[T]) untrack(env *envelope[T]) {
+	broker.counters.bufferedMessages.Add(-1)
+	broker.counters.bufferedBytes.Add(-int64(env.size))
+}
@@ -0,0 +1,85 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryUsage(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[string]().Sizer(func(msg string) int { return len(msg) }).Build()
+	assertions.Equal(MemoryUsage{}, broker.MemoryUsage())
+
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// block the broker loop while it sends the first message
+	assertions.Nil(broker.Publish("a"))
+	time.Sleep(100 * time.Millisecond)
+	assertions.Nil(broker.Publish("bb"))
+	assertions.Nil(broker.Publish("ccc"))
+	assertions.Equal(MemoryUsage{BufferedMessages: 2, BufferedBytes: 5}, broker.MemoryUsage())
+
+	assertions.Equal("a", <-client)
+	assertions.Equal("bb", <-client)
+	assertions.Equal("ccc", <-client)
+	assertions.Equal(MemoryUsage{}, broker.MemoryUsage())
+
+	broker.Close()
+}
+
+func TestMemoryUsageHistory(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[string]().Sizer(func(msg string) int { return len(msg) }).History(2).Build()
+	for _, msg := range []string{"a", "bb", "ccc"} {
+		assertions.Nil(broker.PublishSync(msg))
+	}
+	// the oldest message was replaced
+	assertions.Equal(MemoryUsage{HistoryMessages: 2, HistoryBytes: 5}, broker.MemoryUsage())
+
+	broker.Close()
+}
+
+func TestMemoryUsageClientBuffers(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int64]()
+	client, err := broker.Subscribe(WithBufferSize(3))
+	assertions.Nil(err)
+	_, err = broker.Subscribe(WithBufferSize(1))
+	assertions.Nil(err)
+	assertions.Eventually(func() bool {
+		return broker.MemoryUsage() == MemoryUsage{ClientBufferCapacity: 4, ClientBufferBytes: 32}
+	}, time.Second, 10*time.Millisecond)
+
+	assertions.Nil(broker.Unsubscribe(client))
+	assertions.Equal(MemoryUsage{ClientBufferCapacity: 1, ClientBufferBytes: 8}, broker.MemoryUsage())
+
+	broker.Close()
+	<-broker.Done()
+	assertions.Equal(MemoryUsage{}, broker.MemoryUsage())
+}
+
+func TestMemoryUsageShallowSizer(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int64]().BufferSize(0).Build()
+	_, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// block the broker loop while it sends the first message
+	assertions.Nil(broker.Publish(1))
+	time.Sleep(100 * time.Millisecond)
+	assertions.Equal(MemoryUsage{}, broker.MemoryUsage())
+
+	assertions.ErrorIs(broker.PublishWithOptions(2, WithPublishTimeout(10*time.Millisecond)), ErrTimeout)
+	assertions.Equal(MemoryUsage{}, broker.MemoryUsage())
+	assertions.Equal(8, shallowSizer[int64]()(42))
+
+	broker.Close()
+	time.Sleep(time.Second)
+}
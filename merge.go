@@ -0,0 +1,68 @@
+package broker
+
+import (
+	"context"
+	"sync"
+)
+
+// Merge constructs a new read-only broker, which publishes the messages of all source brokers,
+// using the timeout of the first source and the default buffer size. The messages of each source keep their order,
+// but the messages of different sources are interleaved in the order they arrive.
+// The merged broker is closed when all sources are closed (immediately, if there are no sources),
+// and closing the merged broker unsubscribes it from all sources. Closing the merged broker after its sources
+// closed it has no effect.
+// Returns ErrTimeout on timeout, or ErrClosed if a source is closed.
+func Merge[T any](sources ...*Broker[T]) (*Broker[T], error) {
+	builder := NewBuilder[T]()
+	if len(sources) > 0 {
+		builder = builder.Timeout(sources[0].currentTimeout())
+	}
+	clients := make([]Client[T], 0, len(sources))
+	for _, source := range sources {
+		client, err := source.Subscribe()
+		if err != nil {
+			for i, client := range clients {
+				_ = sources[i].Unsubscribe(client)
+			}
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	merged := builder.Build()
+	merged.readOnly = true
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func(source *Broker[T], client Client[T]) {
+			defer wg.Done()
+			merged.fanIn(source, client)
+		}(sources[i], client)
+	}
+	go func() {
+		wg.Wait()
+		merged.closeBySource()
+	}()
+	return merged, nil
+}
+
+// fanIn publishes the messages of a source client to the merged broker until the source or the merged broker is closed.
+func (broker *Broker[T]) fanIn(source *Broker[T], client Client[T]) {
+	for {
+		select {
+		case msg, ok := <-client:
+			if !ok {
+				return
+			}
+			// the message is discarded on timeout
+			_ = broker.publish(context.Background(), msg)
+		case <-broker.done:
+			// keep receiving, so that the source does not block until the client is closed
+			go func() {
+				for range client {
+				}
+			}()
+			_ = source.Unsubscribe(client)
+			return
+		}
+	}
+}
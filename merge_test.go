@@ -0,0 +1,90 @@
+package broker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	assertions := assert.New(t)
+
+	source1 := New[int]()
+	source2 := New[int]()
+	merged, err := Merge(source1, source2)
+	assertions.Nil(err)
+	client, err := merged.Subscribe(WithBufferSize(10))
+	assertions.Nil(err)
+
+	assertions.Nil(source1.Publish(1))
+	assertions.Nil(source2.Publish(2))
+	assertions.ElementsMatch([]int{1, 2}, []int{<-client, <-client})
+	assertions.ErrorIs(merged.Publish(3), ErrReadOnly)
+
+	// the merged broker is closed after all sources are closed
+	source1.Close()
+	assertions.Nil(source2.Publish(4))
+	assertions.Equal(4, <-client)
+	source2.Close()
+	_, ok := <-client
+	assertions.False(ok)
+	assertions.ErrorIs(merged.Run(context.Background()), ErrClosed)
+}
+
+func TestMergeClose(t *testing.T) {
+	assertions := assert.New(t)
+
+	source1 := New[int]()
+	source2 := New[int]()
+	merged, err := Merge(source1, source2)
+	assertions.Nil(err)
+
+	// closing the merged broker unsubscribes it from all sources
+	merged.Close()
+	assertions.Eventually(func() bool {
+		return source1.Stats().Subscribers == 0 && source2.Stats().Subscribers == 0
+	}, time.Second, 10*time.Millisecond)
+
+	source1.Close()
+	source2.Close()
+}
+
+func TestMergeClosed(t *testing.T) {
+	assertions := assert.New(t)
+
+	source1 := New[int]()
+	source2 := New[int]()
+	source2.Close()
+	merged, err := Merge(source1, source2)
+	assertions.Nil(merged)
+	assertions.ErrorIs(err, ErrClosed)
+	assertions.Equal(0, source1.Stats().Subscribers)
+
+	source1.Close()
+}
+
+func TestMergeEmpty(t *testing.T) {
+	assertions := assert.New(t)
+
+	merged, err := Merge[int]()
+	assertions.Nil(err)
+	assertions.Nil(merged.WaitClosed(context.Background()))
+	assertions.NotPanics(merged.Close)
+}
+
+func TestMergeCloseAfterSources(t *testing.T) {
+	assertions := assert.New(t)
+
+	source1 := New[int]()
+	source2 := New[int]()
+	merged, err := Merge(source1, source2)
+	assertions.Nil(err)
+
+	// the merged broker is closed by its sources, and can still be closed by its owner
+	source1.Close()
+	source2.Close()
+	<-merged.Done()
+	assertions.NotPanics(merged.Close)
+}
@@ -0,0 +1,50 @@
+package broker
+
+// Middleware intercepts the messages of a broker, like for logging, validation, enrichment or metrics.
+// Both functions are optional.
+type Middleware[T any] struct {
+	// Publish is called with every message published to the broker, before it is buffered,
+	// and returns the message to publish instead, or an error to reject publishing the message.
+	// It is called by the publishing goroutine, so it must be safe for concurrent use.
+	Publish func(message T) (T, error)
+	// Deliver is called by the broker loop after it sent a message to a client, or gave up on the client.
+	// It is not called for clients that do not accept the message. It must not block.
+	Deliver func(client Client[T], message T, delivered bool)
+}
+
+// Use configures middleware intercepting the messages of the broker, in addition to the middleware configured
+// already. Published messages pass the middleware in the order it was configured,
+// so that every middleware receives the message returned by the middleware before.
+func (builder Builder[T]) Use(middleware ...Middleware[T]) Builder[T] {
+	builder.middleware = append(builder.middleware, middleware...)
+	return builder
+}
+
+// intercept passes a published message through the middleware of the broker.
+// Returns the error of the middleware that rejected the message.
+func (broker *Broker[T]) intercept(env *envelope[T]) error {
+	if len(broker.middleware) == 0 {
+		return nil
+	}
+	for _, middleware := range broker.middleware {
+		if middleware.Publish == nil {
+			continue
+		}
+		message, err := middleware.Publish(env.message)
+		if err != nil {
+			return broker.error(err)
+		}
+		env.message = message
+	}
+	env.size = broker.sizer(env.message)
+	return nil
+}
+
+// observe notifies the middleware of the broker about the delivery of a message to a client.
+func (broker *Broker[T]) observe(client Client[T], env *envelope[T], delivered bool) {
+	for _, middleware := range broker.middleware {
+		if middleware.Deliver != nil {
+			middleware.Deliver(client, env.message, delivered)
+		}
+	}
+}
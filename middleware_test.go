@@ -0,0 +1,60 @@
+package broker
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUse(t *testing.T) {
+	assertions := assert.New(t)
+
+	errEmpty := errors.New("empty message")
+	var mutex sync.Mutex
+	var deliveries []string
+	broker := NewBuilder[string]().
+		Name("middleware").
+		Timeout(100 * time.Millisecond).
+		Use(Middleware[string]{
+			Publish: func(message string) (string, error) {
+				if message == "" {
+					return "", errEmpty
+				}
+				return strings.ToUpper(message), nil
+			},
+		}).
+		Use(Middleware[string]{
+			Publish: func(message string) (string, error) {
+				return message + "!", nil
+			},
+			Deliver: func(_ Client[string], message string, delivered bool) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				if delivered {
+					deliveries = append(deliveries, message)
+				}
+			},
+		}).
+		Build()
+	client, err := broker.Subscribe(WithBufferSize(10))
+	assertions.Nil(err)
+
+	assertions.Nil(broker.PublishSync("hello"))
+	assertions.ErrorIs(broker.Publish(""), errEmpty)
+	assertions.ErrorIs(broker.TryPublish(""), errEmpty)
+	n, err := broker.PublishBatch([]string{"a", "", "b"})
+	assertions.Equal(1, n)
+	assertions.ErrorIs(err, errEmpty)
+	assertions.Nil(broker.PublishSync("c"))
+
+	assertions.Equal([]string{"HELLO!", "A!", "C!"}, []string{<-client, <-client, <-client})
+	mutex.Lock()
+	assertions.Equal([]string{"HELLO!", "A!", "C!"}, deliveries)
+	mutex.Unlock()
+
+	broker.Close()
+}
@@ -0,0 +1,83 @@
+package broker
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrReadOnly is the error returned when a message is published to a mirror.
+var ErrReadOnly = errors.New("broker is read-only")
+
+// NewMirror constructs a new mirror of the source broker, using the timeout of the source and the default buffer size.
+// Returns ErrTimeout on timeout.
+func NewMirror[T any](source *Broker[T]) (*Broker[T], error) {
+	return NewBuilder[T]().Timeout(source.currentTimeout()).BuildMirror(source)
+}
+
+// BuildMirror subscribes to the source broker and builds a new read-only broker using the configuration of the builder.
+// The mirror broadcasts all messages of the source to its own clients, but rejects messages published to it.
+// The mirror is closed when the source is closed, and closing the mirror unsubscribes it from the source.
+// Closing the mirror after the source closed it has no effect.
+// Returns ErrTimeout on timeout.
+func (builder Builder[T]) BuildMirror(source *Broker[T]) (*Broker[T], error) {
+	client, err := source.Subscribe()
+	if err != nil {
+		return nil, err
+	}
+	mirror := builder.Build()
+	mirror.readOnly = true
+	go forward(mirror, source, client, func(msg T) []T { return []T{msg} })
+	return mirror, nil
+}
+
+// forward publishes the transformed messages of the source client to the target until either broker is closed.
+func forward[T, U any](target *Broker[U], source *Broker[T], client Client[T], transform func(T) []U) {
+	for {
+		select {
+		case msg, ok := <-client:
+			if !ok {
+				target.closeBySource()
+				return
+			}
+			for _, transformed := range transform(msg) {
+				// the message is discarded on timeout
+				_ = target.publish(context.Background(), transformed)
+			}
+		case <-target.done:
+			// keep receiving, so that the source does not block until the client is closed
+			go func() {
+				for range client {
+				}
+			}()
+			_ = source.Unsubscribe(client)
+			return
+		}
+	}
+}
+
+// closeBySource stops a broker derived from other brokers after its sources stopped.
+// The owner of the derived broker can still close it afterwards, which has no effect then.
+func (broker *Broker[T]) closeBySource() {
+	broker.closedBySource.Store(true)
+	broker.close(ErrClosed)
+}
+
+// Tee constructs n mirrors of the source broker, which each receive every message of the source,
+// but buffer and deliver them independently. This isolates the clients of different mirrors from each other,
+// so that slow clients of one mirror only delay the source by the time the mirror needs to buffer a message.
+// Like any mirror, each mirror is closed when the source is closed, and can still be closed afterwards.
+// Returns ErrTimeout on timeout.
+func Tee[T any](source *Broker[T], n int) ([]*Broker[T], error) {
+	mirrors := make([]*Broker[T], 0, n)
+	for i := 0; i < n; i++ {
+		mirror, err := NewMirror(source)
+		if err != nil {
+			for _, mirror := range mirrors {
+				mirror.Close()
+			}
+			return nil, err
+		}
+		mirrors = append(mirrors, mirror)
+	}
+	return mirrors, nil
+}
@@ -0,0 +1,99 @@
+package broker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMirror(t *testing.T) {
+	assertions := assert.New(t)
+
+	source := New[int]()
+	mirror, err := NewMirror(source)
+	assertions.Nil(err)
+	assertions.Equal(source.currentTimeout(), mirror.currentTimeout())
+
+	client, err := mirror.Subscribe()
+	assertions.Nil(err)
+
+	assertions.Nil(source.Publish(1))
+	assertions.Equal(1, <-client)
+	assertions.ErrorIs(mirror.Publish(2), ErrReadOnly)
+	assertions.ErrorIs(mirror.PublishWithOptions(2), ErrReadOnly)
+
+	// the mirror is closed with the source
+	source.Close()
+	_, ok := <-client
+	assertions.False(ok)
+	assertions.ErrorIs(mirror.Run(context.Background()), ErrClosed)
+}
+
+func TestMirrorClose(t *testing.T) {
+	assertions := assert.New(t)
+
+	source := New[int]()
+	mirror, err := NewBuilder[int]().Name("mirror").BuildMirror(source)
+	assertions.Nil(err)
+	assertions.Equal(1, source.Stats().Subscribers)
+	assertions.ErrorContains(mirror.Publish(1), `broker "mirror": broker is read-only`)
+
+	// closing the mirror unsubscribes it from the source
+	mirror.Close()
+	assertions.Eventually(func() bool {
+		return source.Stats().Subscribers == 0
+	}, time.Second, 10*time.Millisecond)
+	assertions.Nil(source.Publish(1))
+
+	source.Close()
+}
+
+func TestMirrorCloseAfterSource(t *testing.T) {
+	assertions := assert.New(t)
+
+	source := New[int]()
+	mirror, err := NewMirror(source)
+	assertions.Nil(err)
+
+	// the mirror is closed by the source, and can still be closed by its owner
+	source.Close()
+	<-mirror.Done()
+	assertions.NotPanics(mirror.Close)
+	assertions.Nil(mirror.CloseWithContext(context.Background()))
+}
+
+func TestTee(t *testing.T) {
+	assertions := assert.New(t)
+
+	source := NewBuilder[int]().Timeout(50 * time.Millisecond).Build()
+	mirrors, err := Tee(source, 2)
+	assertions.Nil(err)
+	assertions.Len(mirrors, 2)
+
+	slow, err := mirrors[0].Subscribe()
+	assertions.Nil(err)
+	fast, err := mirrors[1].Subscribe()
+	assertions.Nil(err)
+
+	// the slow client does not delay the fast client, as its mirror buffers the messages
+	for msg := 0; msg < 5; msg++ {
+		assertions.Nil(source.Publish(msg))
+		assertions.Equal(msg, <-fast)
+	}
+	for msg := 0; msg < 5; msg++ {
+		assertions.Equal(msg, <-slow)
+	}
+
+	source.Close()
+	_, ok := <-fast
+	assertions.False(ok)
+	_, ok = <-slow
+	assertions.False(ok)
+	// the mirrors are closed by the source, and can still be closed by their owner
+	for _, mirror := range mirrors {
+		<-mirror.Done()
+		assertions.NotPanics(mirror.Close)
+	}
+}
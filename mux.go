@@ -0,0 +1,128 @@
+package broker
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// Mux hosts multiple typed brokers behind one facade and routes published messages by their type.
+// In addition, it hosts a named broker for every topic, and for every wildcard pattern matching a family of topics.
+type Mux struct {
+	mutex    sync.Mutex
+	brokers  map[reflect.Type]muxBroker
+	topics   map[topicKey]muxBroker
+	patterns map[topicKey]muxBroker
+	matches  map[topicKey][]muxBroker
+}
+
+// muxBroker is the type-erased view of a broker hosted by a mux.
+type muxBroker interface {
+	publishAny(message any) error
+	close(reason error) bool
+}
+
+// ErrUnknownType is the error returned when a mux hosts no broker for the type of a published message.
+var ErrUnknownType = errors.New("unknown message type")
+
+// ErrAlreadyRegistered is the error returned when a mux already hosts a broker for a type or topic,
+// or already hosts the registered broker.
+var ErrAlreadyRegistered = errors.New("broker already registered")
+
+// publishAny publishes a message of type T passed as any to the broker.
+func (broker *Broker[T]) publishAny(message any) error {
+	return broker.Publish(message.(T))
+}
+
+// NewMux constructs a new mux without any brokers.
+func NewMux() *Mux {
+	return &Mux{
+		brokers:  make(map[reflect.Type]muxBroker),
+		topics:   make(map[topicKey]muxBroker),
+		patterns: make(map[topicKey]muxBroker),
+		matches:  make(map[topicKey][]muxBroker),
+	}
+}
+
+// Publish publishes a message to the broker hosted for the dynamic type of the message.
+// Returns ErrUnknownType if no broker is hosted for that type, or ErrTimeout on timeout.
+func (mux *Mux) Publish(message any) error {
+	mux.mutex.Lock()
+	broker, ok := mux.brokers[reflect.TypeOf(message)]
+	mux.mutex.Unlock()
+	if !ok {
+		return ErrUnknownType
+	}
+	return broker.publishAny(message)
+}
+
+// Close closes all brokers hosted by the mux, including the brokers of topics and patterns, and removes them from it.
+// Brokers that were closed by their owner already are only removed.
+func (mux *Mux) Close() {
+	mux.mutex.Lock()
+	defer mux.mutex.Unlock()
+	for typ, broker := range mux.brokers {
+		broker.close(ErrClosed)
+		delete(mux.brokers, typ)
+	}
+	for key, broker := range mux.topics {
+		broker.close(ErrClosed)
+		delete(mux.topics, key)
+	}
+	for key, broker := range mux.patterns {
+		broker.close(ErrClosed)
+		delete(mux.patterns, key)
+	}
+	for key := range mux.matches {
+		delete(mux.matches, key)
+	}
+}
+
+// Of returns the broker hosted by the mux for type T.
+// A broker with default configuration is created if none is hosted yet.
+func Of[T any](mux *Mux) *Broker[T] {
+	mux.mutex.Lock()
+	defer mux.mutex.Unlock()
+	typ := typeOf[T]()
+	if broker, ok := mux.brokers[typ]; ok {
+		return broker.(*Broker[T])
+	}
+	broker := New[T]()
+	mux.brokers[typ] = broker
+	return broker
+}
+
+// Register hosts a custom configured broker for type T in the mux.
+// Returns ErrAlreadyRegistered if the mux already hosts a broker for type T, or already hosts the broker.
+func Register[T any](mux *Mux, broker *Broker[T]) error {
+	mux.mutex.Lock()
+	defer mux.mutex.Unlock()
+	typ := typeOf[T]()
+	if _, ok := mux.brokers[typ]; ok || mux.hosts(broker) {
+		return ErrAlreadyRegistered
+	}
+	mux.brokers[typ] = broker
+	return nil
+}
+
+// hosts reports whether the mux hosts a broker already, for a type, a topic or a pattern.
+func (mux *Mux) hosts(broker muxBroker) bool {
+	for _, brokers := range []map[topicKey]muxBroker{mux.topics, mux.patterns} {
+		for _, hosted := range brokers {
+			if hosted == broker {
+				return true
+			}
+		}
+	}
+	for _, hosted := range mux.brokers {
+		if hosted == broker {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the reflection type of T, which also works for interface types.
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
@@ -0,0 +1,65 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMuxOf(t *testing.T) {
+	assertions := assert.New(t)
+
+	mux := NewMux()
+	assertions.NotNil(mux)
+
+	intBroker := Of[int](mux)
+	assertions.NotNil(intBroker)
+	assertions.Same(intBroker, Of[int](mux))
+
+	stringBroker := Of[string](mux)
+	assertions.NotNil(stringBroker)
+	assertions.Len(mux.brokers, 2)
+
+	mux.Close()
+	assertions.Empty(mux.brokers)
+	assertions.Panics(intBroker.Close)
+	assertions.Panics(stringBroker.Close)
+}
+
+func TestMuxPublish(t *testing.T) {
+	assertions := assert.New(t)
+
+	mux := NewMux()
+	intClient, err := Of[int](mux).Subscribe()
+	assertions.Nil(err)
+	stringClient, err := Of[string](mux).Subscribe()
+	assertions.Nil(err)
+
+	assertions.Nil(mux.Publish(42))
+	assertions.Nil(mux.Publish("Hello"))
+	assertions.ErrorIs(mux.Publish(4.2), ErrUnknownType)
+	assertions.ErrorIs(mux.Publish(nil), ErrUnknownType)
+
+	assertions.Equal(42, <-intClient)
+	assertions.Equal("Hello", <-stringClient)
+
+	mux.Close()
+}
+
+func TestMuxRegister(t *testing.T) {
+	assertions := assert.New(t)
+
+	mux := NewMux()
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	assertions.Nil(Register(mux, broker))
+	assertions.Same(broker, Of[int](mux))
+
+	other := New[int]()
+	assertions.ErrorIs(Register(mux, other), ErrAlreadyRegistered)
+	other.Close()
+
+	// the mux does not close a broker closed by its owner again
+	broker.Close()
+	assertions.NotPanics(mux.Close)
+}
@@ -0,0 +1,208 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultPipeRetryInterval specifies the default interval before a pipe retries to publish a message.
+const defaultPipeRetryInterval = 10 * time.Millisecond
+
+// maxPipeRetryInterval specifies the maximum interval between the attempts of a pipe to publish a message.
+const maxPipeRetryInterval = time.Second
+
+// Pipe forwards the messages of a source broker to a destination broker.
+type Pipe[T any] struct {
+	source      *Broker[T]
+	destination *Broker[T]
+	client      Client[T]
+	options     pipeOptions
+	stopping    chan void
+	stopOnce    sync.Once
+	done        chan void
+	err         error
+}
+
+// PipeOption configures a pipe.
+type PipeOption func(*pipeOptions)
+
+// pipeOptions holds the configuration applied by pipe options.
+type pipeOptions struct {
+	bufferSize    int
+	errorPolicy   PipeErrorPolicy
+	onError       func(error)
+	retryInterval time.Duration
+}
+
+// PipeErrorPolicy describes what a pipe does when a message cannot be published to the destination broker.
+type PipeErrorPolicy int
+
+const (
+	// SkipOnError means that the pipe discards the message, and forwards the next message.
+	SkipOnError PipeErrorPolicy = iota
+	// RetryOnError means that the pipe publishes the message again, until it succeeds or the pipe is stopped.
+	// The interval between the attempts doubles after every failed attempt, up to one second.
+	RetryOnError
+	// StopOnError means that the pipe discards the message, and stops forwarding.
+	StopOnError
+)
+
+// String returns the name of the error policy.
+func (policy PipeErrorPolicy) String() string {
+	switch policy {
+	case SkipOnError:
+		return "skip"
+	case RetryOnError:
+		return "retry"
+	case StopOnError:
+		return "stop"
+	default:
+		return fmt.Sprintf("PipeErrorPolicy(%d)", int(policy))
+	}
+}
+
+// PipeBufferSize configures the buffer size of the client by which the pipe receives the messages of the source,
+// so that the source does not wait for the pipe while the destination is briefly stalled.
+func PipeBufferSize(bufferSize int) PipeOption {
+	return func(options *pipeOptions) {
+		options.bufferSize = bufferSize
+	}
+}
+
+// PipeOnError configures what the pipe does when a message cannot be published to the destination,
+// and a handler that is passed every such error. By default, such messages are skipped.
+func PipeOnError(policy PipeErrorPolicy, handler func(error)) PipeOption {
+	return func(options *pipeOptions) {
+		options.errorPolicy = policy
+		options.onError = handler
+	}
+}
+
+// PipeRetryInterval configures the interval before the first retry of a message with RetryOnError,
+// which doubles after every failed attempt. Defaults to 10ms.
+func PipeRetryInterval(interval time.Duration) PipeOption {
+	return func(options *pipeOptions) {
+		options.retryInterval = interval
+	}
+}
+
+// NewPipe subscribes to the source broker and forwards all its messages to the destination broker,
+// until the pipe is stopped, either broker is closed, or the error policy stops the pipe.
+// The pipe does not take ownership of the brokers, they must still be closed by the caller.
+// Returns ErrTimeout on timeout, or ErrClosed if the source is closed.
+func NewPipe[T any](source, destination *Broker[T], opts ...PipeOption) (*Pipe[T], error) {
+	options := pipeOptions{retryInterval: defaultPipeRetryInterval}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	client, err := source.Subscribe(WithBufferSize(options.bufferSize))
+	if err != nil {
+		return nil, err
+	}
+	pipe := &Pipe[T]{
+		source:      source,
+		destination: destination,
+		client:      client,
+		options:     options,
+		stopping:    make(chan void),
+		done:        make(chan void),
+	}
+	go pipe.run()
+	return pipe, nil
+}
+
+// Stop stops forwarding and unsubscribes the pipe from the source broker, and blocks until the pipe stopped.
+// Messages buffered by the pipe are discarded. Returns ErrTimeout on timeout.
+func (pipe *Pipe[T]) Stop() error {
+	pipe.stopOnce.Do(func() { close(pipe.stopping) })
+	select {
+	case <-pipe.done:
+		return nil
+	default:
+	}
+	if err := pipe.source.Unsubscribe(pipe.client); err != nil && !errors.Is(err, ErrClosed) {
+		return err
+	}
+	<-pipe.done
+	return nil
+}
+
+// Done returns a channel that is closed when the pipe stopped forwarding.
+func (pipe *Pipe[T]) Done() <-chan struct{} {
+	return pipe.done
+}
+
+// Err returns the error that stopped the pipe: ErrClosed if the destination was closed,
+// ErrReadOnly if the destination is a mirror, or the error of the message that stopped the pipe with StopOnError.
+// Returns nil if the pipe was stopped, the source was closed, or the pipe is still forwarding.
+func (pipe *Pipe[T]) Err() error {
+	select {
+	case <-pipe.done:
+		return pipe.err
+	default:
+		return nil
+	}
+}
+
+// run starts the pipe loop, which forwards the messages of the source until it is stopped.
+func (pipe *Pipe[T]) run() {
+	defer close(pipe.done)
+	for {
+		select {
+		case msg, ok := <-pipe.client:
+			if !ok {
+				return
+			}
+			if err := pipe.forward(msg); err != nil {
+				pipe.err = err
+				// keep receiving, so that the source does not block until the client is closed
+				go func() {
+					for range pipe.client {
+					}
+				}()
+				_ = pipe.source.Unsubscribe(pipe.client)
+				return
+			}
+		case <-pipe.stopping:
+			return
+		}
+	}
+}
+
+// forward publishes a message to the destination, applying the error policy.
+// Returns an error if the pipe must stop.
+func (pipe *Pipe[T]) forward(msg T) error {
+	interval := pipe.options.retryInterval
+	for {
+		err := pipe.destination.Publish(msg)
+		if err == nil {
+			return nil
+		}
+		if pipe.options.onError != nil {
+			pipe.options.onError(err)
+		}
+		if errors.Is(err, ErrClosed) || errors.Is(err, ErrReadOnly) {
+			// the destination never accepts messages again
+			return err
+		}
+		switch pipe.options.errorPolicy {
+		case RetryOnError:
+			timer := time.NewTimer(interval)
+			select {
+			case <-pipe.stopping:
+				timer.Stop()
+				return nil
+			case <-timer.C:
+			}
+			if interval *= 2; interval > maxPipeRetryInterval {
+				interval = maxPipeRetryInterval
+			}
+		case StopOnError:
+			return err
+		default:
+			return nil
+		}
+	}
+}
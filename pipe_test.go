@@ -0,0 +1,153 @@
+package broker
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipe(t *testing.T) {
+	assertions := assert.New(t)
+
+	source := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	destination := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	client, err := destination.Subscribe(WithBufferSize(10))
+	assertions.Nil(err)
+	pipe, err := NewPipe(source, destination, PipeBufferSize(10))
+	assertions.Nil(err)
+
+	for msg := 1; msg <= 3; msg++ {
+		assertions.Nil(source.Publish(msg))
+	}
+	assertions.Equal([]int{1, 2, 3}, []int{<-client, <-client, <-client})
+
+	assertions.Nil(pipe.Stop())
+	assertions.Nil(pipe.Stop())
+	assertions.Nil(pipe.Err())
+	assertions.Equal(0, source.Stats().Subscribers)
+	assertions.Nil(source.Publish(4))
+	select {
+	case <-client:
+		assertions.Fail("Received message not expected")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	source.Close()
+	destination.Close()
+}
+
+func TestPipeDestinationClosed(t *testing.T) {
+	assertions := assert.New(t)
+
+	source := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	destination := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	pipe, err := NewPipe(source, destination, PipeOnError(RetryOnError, nil))
+	assertions.Nil(err)
+
+	destination.Close()
+	assertions.Nil(source.Publish(1))
+	<-pipe.Done()
+	assertions.ErrorIs(pipe.Err(), ErrClosed)
+	assertions.Eventually(func() bool {
+		return source.Stats().Subscribers == 0
+	}, time.Second, 10*time.Millisecond)
+
+	source.Close()
+}
+
+func TestPipeErrorPolicies(t *testing.T) {
+	assertions := assert.New(t)
+
+	errOdd := errors.New("odd message")
+	rejectOdd := Middleware[int]{Publish: func(msg int) (int, error) {
+		if msg%2 != 0 {
+			return 0, errOdd
+		}
+		return msg, nil
+	}}
+	source := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	destination := NewBuilder[int]().Timeout(100 * time.Millisecond).Use(rejectOdd).Build()
+	client, err := destination.Subscribe(WithBufferSize(10))
+	assertions.Nil(err)
+
+	errs := make(chan error, 10)
+	skipping, err := NewPipe(source, destination, PipeOnError(SkipOnError, func(err error) { errs <- err }))
+	assertions.Nil(err)
+	stopping, err := NewPipe(source, destination, PipeOnError(StopOnError, nil))
+	assertions.Nil(err)
+
+	assertions.Nil(source.Publish(2))
+	assertions.Nil(source.Publish(1))
+	<-stopping.Done()
+	assertions.ErrorIs(stopping.Err(), errOdd)
+	assertions.ErrorIs(<-errs, errOdd)
+	assertions.Nil(source.Publish(4))
+	assertions.Equal([]int{2, 2, 4}, []int{<-client, <-client, <-client})
+	assertions.Nil(skipping.Stop())
+	assertions.Nil(stopping.Stop())
+
+	source.Close()
+	destination.Close()
+}
+
+func TestPipeRetryOnError(t *testing.T) {
+	assertions := assert.New(t)
+
+	errVeto := errors.New("veto")
+	var vetoed atomic.Int32
+	veto := Middleware[int]{Publish: func(msg int) (int, error) {
+		if vetoed.Add(1) <= 3 {
+			return 0, errVeto
+		}
+		return msg, nil
+	}}
+	source := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	destination := NewBuilder[int]().Timeout(100 * time.Millisecond).Use(veto).Build()
+	client, err := destination.Subscribe(WithBufferSize(10))
+	assertions.Nil(err)
+	errs := make(chan error, 10)
+	pipe, err := NewPipe(source, destination,
+		PipeOnError(RetryOnError, func(err error) { errs <- err }), PipeRetryInterval(20*time.Millisecond))
+	assertions.Nil(err)
+
+	// the pipe backs off between the attempts: 20ms, 40ms, 80ms
+	start := time.Now()
+	assertions.Nil(source.Publish(1))
+	assertions.Equal(1, <-client)
+	assertions.GreaterOrEqual(time.Since(start), 140*time.Millisecond)
+	assertions.Len(errs, 3)
+	assertions.Nil(pipe.Stop())
+
+	source.Close()
+	destination.Close()
+}
+
+func TestPipeReadOnlyDestination(t *testing.T) {
+	assertions := assert.New(t)
+
+	source := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	other := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	mirror, err := NewMirror(other)
+	assertions.Nil(err)
+	pipe, err := NewPipe(source, mirror, PipeOnError(RetryOnError, nil))
+	assertions.Nil(err)
+
+	assertions.Nil(source.Publish(1))
+	<-pipe.Done()
+	assertions.ErrorIs(pipe.Err(), ErrReadOnly)
+
+	source.Close()
+	other.Close()
+}
+
+func TestPipeErrorPolicyString(t *testing.T) {
+	assertions := assert.New(t)
+
+	assertions.Equal("skip", SkipOnError.String())
+	assertions.Equal("retry", RetryOnError.String())
+	assertions.Equal("stop", StopOnError.String())
+	assertions.Equal("PipeErrorPolicy(42)", PipeErrorPolicy(42).String())
+}
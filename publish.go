@@ -0,0 +1,397 @@
+package broker
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// PublishOption configures the publishing of a single message.
+type PublishOption func(*publishOptions)
+
+// publishOptions holds the per-message configuration applied by publish options.
+type publishOptions struct {
+	ttl            time.Duration
+	priority       int
+	key            string
+	headers        map[string]string
+	timeout        time.Duration
+	deadline       time.Time
+	idempotencyKey string
+}
+
+// envelope wraps a published message with its per-message attributes.
+type envelope[T any] struct {
+	message        T
+	published      time.Time
+	expires        time.Time
+	due            time.Time
+	priority       int
+	key            string
+	idempotencyKey string
+	headers        map[string]string
+	size           int
+	sequence       uint64
+	ctx            context.Context
+	result         chan error
+	outcome        chan Outcome
+	quorum         *quorum[T]
+	routes         []RouteKey
+	routed         bool
+	dropped        bool
+	logged         bool
+	offset         uint64
+}
+
+// WithTTL configures the time to live of a message.
+// The message is discarded if it is not broadcast before the time to live elapsed.
+func WithTTL(ttl time.Duration) PublishOption {
+	return func(options *publishOptions) {
+		options.ttl = ttl
+	}
+}
+
+// WithPriority configures the priority of a message.
+// Buffered messages with a higher priority are broadcast before messages with a lower priority.
+// Messages with the same priority are broadcast in the order they were published. The default priority is 0.
+func WithPriority(priority int) PublishOption {
+	return func(options *publishOptions) {
+		options.priority = priority
+	}
+}
+
+// WithKey configures the key of a message, which is not sent to the clients.
+// Within a group (or in round-robin mode), all messages with the same key are sent to the same client,
+// and in conflation mode, a pending message is replaced by a newer message with the same key.
+// The key is also recorded in the trace of the message.
+func WithKey(key string) PublishOption {
+	return func(options *publishOptions) {
+		options.key = key
+	}
+}
+
+// WithHeader adds a header to a message, which is not sent to the clients, but passed to the enrichers
+// of the broker and recorded in the trace of the message. Otherwise, the header has no effect.
+func WithHeader(name, value string) PublishOption {
+	return func(options *publishOptions) {
+		if options.headers == nil {
+			options.headers = make(map[string]string)
+		}
+		options.headers[name] = value
+	}
+}
+
+// WithPublishTimeout overrides the broker timeout for publishing a message.
+// Unlike the broker timeout, it also applies when publishing with a context, whichever expires first.
+func WithPublishTimeout(timeout time.Duration) PublishOption {
+	return func(options *publishOptions) {
+		options.timeout = timeout
+	}
+}
+
+// WithContextDeadline carries the deadline of the context (if any) with a message.
+// The message is discarded like an expired message if it is not broadcast before the deadline passed,
+// so that work abandoned by the publisher is not fanned out to the clients.
+func WithContextDeadline(ctx context.Context) PublishOption {
+	return func(options *publishOptions) {
+		options.deadline, _ = ctx.Deadline()
+	}
+}
+
+// PublishWithOptions publishes a message with per-message options to the broker.
+// Returns ErrTimeout on timeout, ErrClosed if the broker is closed, or ErrReadOnly if the broker is a mirror.
+func (broker *Broker[T]) PublishWithOptions(message T, opts ...PublishOption) error {
+	if broker.readOnly {
+		return broker.error(ErrReadOnly)
+	}
+	return broker.publish(context.Background(), message, opts...)
+}
+
+// PublishContext publishes a message with per-message options to the broker.
+// Instead of the broker timeout, it waits until the context is done, unless the context can never be done.
+// A timeout configured by WithPublishTimeout applies in addition, whichever expires first.
+// Returns the context error if the context is done, ErrTimeout on timeout, ErrClosed if the broker is closed,
+// or ErrReadOnly if the broker is a mirror.
+func (broker *Broker[T]) PublishContext(ctx context.Context, message T, opts ...PublishOption) error {
+	if broker.readOnly {
+		return broker.error(ErrReadOnly)
+	}
+	return broker.publish(ctx, message, opts...)
+}
+
+// PublishBatch publishes messages to the broker in order, with a single timeout for all of them.
+// Returns how many messages were published, which are the leading messages in case of an error.
+// Returns ErrTimeout on timeout, ErrClosed if the broker is closed, or ErrReadOnly if the broker is a mirror.
+func (broker *Broker[T]) PublishBatch(messages []T) (int, error) {
+	if broker.readOnly {
+		return 0, broker.error(ErrReadOnly)
+	}
+	envs := make([]envelope[T], len(messages))
+	for i, message := range messages {
+		envs[i] = broker.wrap(message, publishOptions{})
+	}
+	return broker.sendAll(context.Background(), envs, time.After(broker.currentTimeout()))
+}
+
+// ErrBufferFull is the error returned when a message cannot be published without waiting for the broker.
+var ErrBufferFull = errors.New("buffer full")
+
+// TryPublish publishes a message to the broker only if the broker accepts it immediately, without ever waiting,
+// neither for other publishers nor for a resize of the buffer. The message bypasses the admission of fair publishing.
+// Returns ErrBufferFull if the buffer is full, ErrClosed if the broker is closed,
+// or ErrReadOnly if the broker is a mirror.
+func (broker *Broker[T]) TryPublish(message T) error {
+	if broker.readOnly {
+		return broker.error(ErrReadOnly)
+	}
+	if broker.closed.Load() {
+		return broker.error(ErrClosed)
+	}
+	env := broker.wrap(message, publishOptions{})
+	if err := broker.intercept(&env); err != nil {
+		return err
+	}
+	if err := broker.log(&env); err != nil {
+		return err
+	}
+	buffer := broker.acquire()
+	defer buffer.release()
+	broker.track(&env)
+	select {
+	case buffer.messages <- env:
+		broker.counters.published.Add(1)
+		return nil
+	default:
+		broker.untrack(&env)
+		broker.acknowledge(&env)
+		return broker.error(ErrBufferFull)
+	}
+}
+
+// publish publishes a message with per-message options to the broker, even if the broker is a mirror.
+func (broker *Broker[T]) publish(ctx context.Context, message T, opts ...PublishOption) error {
+	var options publishOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	env := broker.wrap(message, options)
+	timeout := broker.deadline(ctx, broker.currentTimeout())
+	if options.timeout > 0 {
+		// the publish timeout applies even if the context can be done
+		timeout = time.After(options.timeout)
+	}
+	return broker.send(ctx, &env, timeout)
+}
+
+// wrap wraps a message and its options in an envelope, and applies the enrichers of the broker.
+func (broker *Broker[T]) wrap(message T, options publishOptions) envelope[T] {
+	env := envelope[T]{
+		message:        message,
+		published:      time.Now(),
+		priority:       options.priority,
+		key:            options.key,
+		idempotencyKey: options.idempotencyKey,
+		headers:        options.headers,
+		size:           broker.sizer(message),
+	}
+	if options.ttl > 0 {
+		env.expires = env.published.Add(options.ttl)
+	}
+	if !options.deadline.IsZero() && (env.expires.IsZero() || options.deadline.Before(env.expires)) {
+		env.expires = options.deadline
+	}
+	for _, enrich := range broker.enrichers {
+		if env.headers == nil {
+			env.headers = make(map[string]string)
+		}
+		enrich(message, env.headers)
+	}
+	return env
+}
+
+// send sends a message to the buffer of the broker.
+// Returns ErrTimeout on timeout, ErrClosed if the broker is closed, or the context error if the context is done.
+func (broker *Broker[T]) send(ctx context.Context, env *envelope[T], timeout <-chan time.Time) error {
+	_, err := broker.sendAll(ctx, []envelope[T]{*env}, timeout)
+	return err
+}
+
+// sendAll sends messages to the buffer of the broker in order, and returns how many were sent.
+// Returns ErrTimeout on timeout, ErrClosed if the broker is closed, or the context error if the context is done.
+func (broker *Broker[T]) sendAll(ctx context.Context, envs []envelope[T], timeout <-chan time.Time) (int, error) {
+	cancel := ctx.Done()
+	if broker.admission != nil {
+		// wait until all publishers that arrived earlier sent their messages
+		turn := broker.admission.enter()
+		defer broker.admission.leave(turn)
+		select {
+		case <-turn:
+		case <-timeout:
+			return 0, broker.error(ErrTimeout)
+		case <-broker.stop:
+			return 0, broker.error(ErrClosed)
+		case <-cancel:
+			return 0, broker.contextError(ctx.Err())
+		}
+	}
+	if broker.closed.Load() {
+		// the buffer may have space left, but the broker loop does not receive messages anymore
+		return 0, broker.error(ErrClosed)
+	}
+	// send all messages to the same buffer, so that they keep their order even if the buffer is resized meanwhile
+	buffer := broker.acquire()
+	defer buffer.release()
+	for i := range envs {
+		env := &envs[i]
+		if err := broker.intercept(env); err != nil {
+			return i, err
+		}
+		if err := broker.log(env); err != nil {
+			return i, err
+		}
+		// track the message before sending it, as the broker loop may untrack it immediately
+		broker.track(env)
+		select {
+		case buffer.messages <- *env:
+			broker.counters.published.Add(1)
+		case <-timeout:
+			broker.untrack(env)
+			broker.acknowledge(env)
+			return i, broker.error(ErrTimeout)
+		case <-broker.stop:
+			broker.untrack(env)
+			broker.acknowledge(env)
+			return i, broker.error(ErrClosed)
+		case <-cancel:
+			broker.untrack(env)
+			broker.acknowledge(env)
+			return i, broker.contextError(ctx.Err())
+		}
+	}
+	return len(envs), nil
+}
+
+// messageBuffer is a buffer of published messages, which counts the publishers sending to it.
+type messageBuffer[T any] struct {
+	messages chan envelope[T]
+	senders  atomic.Int64
+}
+
+// newMessageBuffer constructs a new message buffer of the size.
+func newMessageBuffer[T any](size int) *messageBuffer[T] {
+	return &messageBuffer[T]{messages: make(chan envelope[T], size)}
+}
+
+// acquire returns the current buffer of the broker for sending messages to it.
+// The broker loop keeps receiving from the buffer until it is released, even if the buffer is replaced meanwhile.
+func (broker *Broker[T]) acquire() *messageBuffer[T] {
+	for {
+		buffer := broker.messages.Load()
+		buffer.senders.Add(1)
+		if broker.messages.Load() == buffer {
+			return buffer
+		}
+		// the buffer was replaced before the broker loop could see the sender, so send to the new buffer
+		buffer.release()
+	}
+}
+
+// release releases a buffer acquired for sending messages.
+func (buffer *messageBuffer[T]) release() {
+	buffer.senders.Add(-1)
+}
+
+// expired reports whether the time to live of the message elapsed.
+func (env *envelope[T]) expired(now time.Time) bool {
+	return !env.expires.IsZero() && now.After(env.expires)
+}
+
+// expire discards a message whose time to live elapsed.
+func (broker *Broker[T]) expire(env *envelope[T]) {
+	broker.counters.expired.Add(1)
+	if env.outcome != nil {
+		env.outcome <- Outcome{Expired: true}
+	}
+	if broker.tracer != nil {
+		trace := env.trace()
+		trace.Expired = true
+		broker.tracer(*trace)
+	}
+}
+
+// sweep discards all buffered and pending messages whose time to live elapsed.
+func (broker *Broker[T]) sweep(now time.Time) {
+	for len(broker.buffer.messages) > 0 {
+		broker.enqueue(<-broker.buffer.messages)
+	}
+	envelopes := broker.pending.envelopes[:0]
+	for i := range broker.pending.envelopes {
+		env := broker.pending.envelopes[i]
+		if !env.expired(now) {
+			envelopes = append(envelopes, env)
+			continue
+		}
+		broker.untrack(&env)
+		broker.expire(&env)
+		broker.acknowledge(&env)
+	}
+	for i := len(envelopes); i < len(broker.pending.envelopes); i++ {
+		broker.pending.envelopes[i] = envelope[T]{}
+	}
+	broker.pending.envelopes = envelopes
+	heap.Init(&broker.pending)
+}
+
+// pendingQueue holds the messages taken from the buffer that are not broadcast yet,
+// ordered by priority and publishing sequence. It implements heap.Interface.
+type pendingQueue[T any] struct {
+	envelopes []envelope[T]
+	sequence  uint64
+}
+
+// Len implements heap.Interface.
+func (queue *pendingQueue[T]) Len() int {
+	return len(queue.envelopes)
+}
+
+// Less implements heap.Interface.
+func (queue *pendingQueue[T]) Less(i, j int) bool {
+	a, b := &queue.envelopes[i], &queue.envelopes[j]
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.sequence < b.sequence
+}
+
+// Swap implements heap.Interface.
+func (queue *pendingQueue[T]) Swap(i, j int) {
+	queue.envelopes[i], queue.envelopes[j] = queue.envelopes[j], queue.envelopes[i]
+}
+
+// Push implements heap.Interface.
+func (queue *pendingQueue[T]) Push(x any) {
+	queue.envelopes = append(queue.envelopes, x.(envelope[T]))
+}
+
+// Pop implements heap.Interface.
+func (queue *pendingQueue[T]) Pop() any {
+	n := len(queue.envelopes) - 1
+	env := queue.envelopes[n]
+	queue.envelopes[n] = envelope[T]{}
+	queue.envelopes = queue.envelopes[:n]
+	return env
+}
+
+// push adds a message to the queue.
+func (queue *pendingQueue[T]) push(env envelope[T]) {
+	queue.sequence++
+	env.sequence = queue.sequence
+	heap.Push(queue, env)
+}
+
+// pop removes the message with the highest priority from the queue.
+func (queue *pendingQueue[T]) pop() envelope[T] {
+	return heap.Pop(queue).(envelope[T])
+}
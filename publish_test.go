@@ -0,0 +1,232 @@
+package broker
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishOptions(t *testing.T) {
+	assertions := assert.New(t)
+
+	options := publishOptions{}
+	for _, opt := range []PublishOption{
+		WithTTL(time.Second),
+		WithPriority(3),
+		WithKey("key"),
+		WithHeader("a", "1"),
+		WithHeader("b", "2"),
+		WithPublishTimeout(time.Minute),
+	} {
+		opt(&options)
+	}
+	assertions.Equal(publishOptions{
+		ttl:      time.Second,
+		priority: 3,
+		key:      "key",
+		headers:  map[string]string{"a": "1", "b": "2"},
+		timeout:  time.Minute,
+	}, options)
+}
+
+func TestPublishWithPriority(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// block the broker loop while it sends the first message
+	assertions.Nil(broker.Publish(0))
+	time.Sleep(100 * time.Millisecond)
+
+	assertions.Nil(broker.PublishWithOptions(1))
+	assertions.Nil(broker.PublishWithOptions(2, WithPriority(5)))
+	assertions.Nil(broker.PublishWithOptions(3, WithPriority(1)))
+	assertions.Nil(broker.PublishWithOptions(4, WithPriority(5)))
+
+	for _, expected := range []int{0, 2, 4, 3, 1} {
+		assertions.Equal(expected, <-client)
+	}
+
+	broker.Close()
+}
+
+func TestPublishWithTTL(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// block the broker loop while it sends the first message
+	assertions.Nil(broker.Publish(0))
+	time.Sleep(100 * time.Millisecond)
+
+	assertions.Nil(broker.PublishWithOptions(1, WithTTL(10*time.Millisecond)))
+	assertions.Nil(broker.PublishWithOptions(2, WithTTL(time.Minute)))
+	time.Sleep(100 * time.Millisecond)
+
+	assertions.Equal(0, <-client)
+	assertions.Equal(2, <-client)
+	assertions.Equal(uint64(1), broker.Stats().Expired)
+
+	broker.Close()
+}
+
+func TestPublishWithContextDeadline(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// block the broker loop while it sends the first message
+	assertions.Nil(broker.Publish(0))
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assertions.Nil(broker.PublishWithOptions(1, WithContextDeadline(ctx)))
+	assertions.Nil(broker.PublishWithOptions(2, WithContextDeadline(context.Background())))
+	time.Sleep(100 * time.Millisecond)
+
+	assertions.Equal(0, <-client)
+	assertions.Equal(2, <-client)
+	assertions.Equal(uint64(1), broker.Stats().Expired)
+
+	broker.Close()
+}
+
+func TestPublishWithPublishTimeout(t *testing.T) {
+	assertions := assert.New(t)
+
+	timeout := 100 * time.Millisecond
+	broker := NewBuilder[int]().Timeout(timeout).BufferSize(0).Build()
+	_, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// block the broker loop while it sends the first message
+	assertions.Nil(broker.Publish(0))
+
+	start := time.Now()
+	assertions.ErrorIs(broker.PublishWithOptions(1, WithPublishTimeout(10*time.Millisecond)), ErrTimeout)
+	assertions.Less(time.Since(start), timeout)
+
+	broker.Close()
+}
+
+func TestPublishBatch(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).BufferSize(2).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	n, err := broker.PublishBatch([]int{1, 2})
+	assertions.Equal(2, n)
+	assertions.Nil(err)
+	assertions.Equal(1, <-client)
+	assertions.Equal(2, <-client)
+
+	// the broker loop is blocked while it sends the first message, so only the leading messages are published
+	n, err = broker.PublishBatch(make([]int, 10))
+	assertions.Less(n, 10)
+	assertions.ErrorIs(err, ErrTimeout)
+	assertions.Equal(uint64(2+n), broker.Stats().Published)
+
+	broker.Close()
+	n, err = broker.PublishBatch([]int{7})
+	assertions.Zero(n)
+	assertions.ErrorIs(err, ErrClosed)
+}
+
+func TestTryPublish(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(time.Minute).BufferSize(1).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// block the broker loop while it sends the first message
+	assertions.Nil(broker.TryPublish(0))
+	time.Sleep(50 * time.Millisecond)
+
+	assertions.Nil(broker.TryPublish(1))
+	start := time.Now()
+	assertions.ErrorIs(broker.TryPublish(2), ErrBufferFull)
+	assertions.Less(time.Since(start), 10*time.Millisecond)
+
+	// a publisher waiting for the full buffer neither delays a resize of the buffer nor publishing without waiting
+	published := make(chan error)
+	go func() {
+		published <- broker.Publish(3)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	bufferSize := 2
+	start = time.Now()
+	assertions.Nil(broker.ApplyConfig(BrokerConfig{BufferSize: &bufferSize}))
+	assertions.Nil(broker.TryPublish(4))
+	assertions.Less(time.Since(start), 50*time.Millisecond)
+
+	assertions.Equal(0, <-client)
+	assertions.Equal(1, <-client)
+	assertions.ElementsMatch([]int{3, 4}, []int{<-client, <-client})
+	assertions.Nil(<-published)
+	assertions.Equal(uint64(4), broker.Stats().Published)
+
+	broker.Close()
+	assertions.ErrorIs(broker.TryPublish(5), ErrClosed)
+}
+
+func TestEnrich(t *testing.T) {
+	assertions := assert.New(t)
+
+	traces := make(chan Trace[string], 2)
+	broker := NewBuilder[string]().
+		Enrich(func(msg string, headers map[string]string) {
+			headers["region"] = "eu"
+			headers["length"] = strconv.Itoa(len(msg))
+		}).
+		Enrich(func(msg string, headers map[string]string) {
+			delete(headers, "internal")
+		}).
+		Trace(func(trace Trace[string]) { traces <- trace }).
+		Build()
+
+	assertions.Nil(broker.Publish("abc"))
+	assertions.Equal(map[string]string{"region": "eu", "length": "3"}, (<-traces).Headers)
+
+	// the enrichers are applied after the headers set by the publisher
+	assertions.Nil(broker.PublishWithOptions("a",
+		WithHeader("region", "us"), WithHeader("internal", "x"), WithHeader("lang", "en")))
+	assertions.Equal(map[string]string{"region": "eu", "length": "1", "lang": "en"}, (<-traces).Headers)
+
+	broker.Close()
+}
+
+func TestExpirySweep(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).ExpirySweep(10 * time.Millisecond).Build()
+	_, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// the client never receives, so that the broker loop blocks while it sends each message
+	assertions.Nil(broker.Publish(1))
+	time.Sleep(10 * time.Millisecond)
+	assertions.Nil(broker.Publish(2))
+	assertions.Nil(broker.PublishWithOptions(3, WithTTL(time.Millisecond)))
+	assertions.Equal(2, broker.MemoryUsage().BufferedMessages)
+
+	// the expired message is discarded before the broadcast of the message before it completes
+	time.Sleep(150 * time.Millisecond)
+	assertions.Equal(uint64(1), broker.Stats().Expired)
+	assertions.Equal(MemoryUsage{}, broker.MemoryUsage())
+
+	broker.Close()
+	time.Sleep(100 * time.Millisecond)
+}
@@ -0,0 +1,72 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNoQuorum is the error returned when fewer clients than the quorum received a message.
+var ErrNoQuorum = errors.New("quorum not reached")
+
+// quorum tracks the clients that received a message published with a quorum.
+type quorum[T any] struct {
+	size     int
+	received []Client[T]
+	reached  chan []Client[T]
+}
+
+// receive records that a client received the message, and reports the clients once the quorum is reached.
+func (quorum *quorum[T]) receive(client Client[T]) {
+	if len(quorum.received) == quorum.size {
+		return
+	}
+	quorum.received = append(quorum.received, client)
+	if len(quorum.received) == quorum.size {
+		quorum.reached <- quorum.received
+	}
+}
+
+// PublishQuorum publishes a message to the broker, and blocks until at least quorum clients received it.
+// Returns the clients that received the message when the quorum was reached, while the broker keeps sending
+// the message to the remaining clients. The quorum must be positive.
+// Returns ErrNoQuorum if fewer clients received the message, ErrClosed if the broker is closed,
+// ErrReadOnly if the broker is a mirror, ErrTimeout on timeout, or the context error if the context is done.
+func (broker *Broker[T]) PublishQuorum(ctx context.Context, message T, quorum int) ([]Client[T], error) {
+	if broker.readOnly {
+		return nil, broker.error(ErrReadOnly)
+	}
+	if broker.closed.Load() {
+		return nil, broker.error(ErrClosed)
+	}
+	env := broker.wrap(message, publishOptions{})
+	env.result = make(chan error, 1)
+	env.quorum = newQuorum[T](quorum)
+	if err := broker.send(ctx, &env, time.After(broker.currentTimeout())); err != nil {
+		return nil, err
+	}
+	select {
+	case clients := <-env.quorum.reached:
+		return clients, nil
+	case err := <-env.result:
+		// the quorum may be reached by the last client of the broadcast
+		select {
+		case clients := <-env.quorum.reached:
+			return clients, nil
+		default:
+		}
+		if err == nil {
+			err = ErrNoQuorum
+		}
+		return nil, broker.error(err)
+	case <-broker.done:
+		return nil, broker.error(ErrClosed)
+	case <-ctx.Done():
+		return nil, broker.contextError(ctx.Err())
+	}
+}
+
+// newQuorum constructs a new quorum of the given size.
+func newQuorum[T any](size int) *quorum[T] {
+	return &quorum[T]{size: size, reached: make(chan []Client[T], 1)}
+}
@@ -0,0 +1,45 @@
+package broker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishQuorum(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(50 * time.Millisecond).Build()
+	var receiving []Client[int]
+	for i := 0; i < 2; i++ {
+		client, err := broker.Subscribe()
+		assertions.Nil(err)
+		receiving = append(receiving, client)
+		go func() {
+			for range client {
+			}
+		}()
+	}
+	// the third client never receives
+	_, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	clients, err := broker.PublishQuorum(context.Background(), 1, 2)
+	assertions.Nil(err)
+	assertions.ElementsMatch(receiving, clients)
+
+	_, err = broker.PublishQuorum(context.Background(), 2, 3)
+	assertions.ErrorIs(err, ErrNoQuorum)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = broker.PublishQuorum(ctx, 3, 3)
+	assertions.ErrorIs(err, context.Canceled)
+
+	broker.Close()
+	_, err = broker.PublishQuorum(context.Background(), 4, 1)
+	assertions.ErrorIs(err, ErrClosed)
+	time.Sleep(100 * time.Millisecond)
+}
@@ -0,0 +1,80 @@
+package broker
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// rateInterval specifies the interval in which the broker updates its rates.
+const rateInterval = 5 * time.Second
+
+// Rates holds rates of the counters of a broker in messages per second,
+// as exponentially weighted moving averages over a window.
+type Rates struct {
+	// Published is the rate of messages accepted by the broker.
+	Published float64
+	// Delivered is the rate of messages sent to clients.
+	Delivered float64
+	// Dropped is the rate of messages discarded because a client did not receive them in time.
+	Dropped float64
+}
+
+// rates holds the moving averages of a broker, and the counter values they were last updated with.
+type rates struct {
+	mutex       sync.Mutex
+	updated     time.Time
+	published   uint64
+	delivered   uint64
+	dropped     uint64
+	oneMinute   Rates
+	fiveMinutes Rates
+}
+
+// updateRates updates the moving averages with the counters accumulated since the previous update.
+func (broker *Broker[T]) updateRates(now time.Time) {
+	r := &broker.rates
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	published := broker.counters.published.Load()
+	delivered := broker.counters.delivered.Load()
+	dropped := broker.counters.dropped.Load()
+	if !r.updated.IsZero() {
+		elapsed := now.Sub(r.updated).Seconds()
+		if elapsed <= 0 {
+			return
+		}
+		current := Rates{
+			Published: float64(published-r.published) / elapsed,
+			Delivered: float64(delivered-r.delivered) / elapsed,
+			Dropped:   float64(dropped-r.dropped) / elapsed,
+		}
+		r.oneMinute.decay(current, elapsed, time.Minute)
+		r.fiveMinutes.decay(current, elapsed, 5*time.Minute)
+	}
+	r.updated, r.published, r.delivered, r.dropped = now, published, delivered, dropped
+}
+
+// decay moves the averages towards the current rates, weighted by the elapsed seconds relative to the window.
+func (averages *Rates) decay(current Rates, elapsed float64, window time.Duration) {
+	alpha := 1 - math.Exp(-elapsed/window.Seconds())
+	averages.Published += alpha * (current.Published - averages.Published)
+	averages.Delivered += alpha * (current.Delivered - averages.Delivered)
+	averages.Dropped += alpha * (current.Dropped - averages.Dropped)
+}
+
+// ResetStats resets the counters and rates of the broker to zero.
+// The number of subscribers and buffered messages are not affected, as they reflect the current state of the broker.
+func (broker *Broker[T]) ResetStats() {
+	r := &broker.rates
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	broker.counters.published.Store(0)
+	broker.counters.broadcasts.Store(0)
+	broker.counters.delivered.Store(0)
+	broker.counters.dropped.Store(0)
+	broker.counters.expired.Store(0)
+	broker.counters.broadcastTime.Store(0)
+	r.published, r.delivered, r.dropped = 0, 0, 0
+	r.oneMinute, r.fiveMinutes = Rates{}, Rates{}
+}
@@ -0,0 +1,144 @@
+package broker
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoResponder is the error returned when a request is sent to a broker without responders.
+var ErrNoResponder = errors.New("no responder")
+
+// request carries a request message to the responders of a broker, and the reply back to the requester.
+// Messages are untyped, as a request broker derived from the type of the broker would be instantiated endlessly.
+type request struct {
+	message any
+	reply   chan response
+}
+
+// response is the reply to a request, or the error of the responder.
+type response struct {
+	message any
+	err     error
+}
+
+// responderGroup is the group of the responder clients, so that every request is handled by only one responder.
+const responderGroup = "responders"
+
+// Responder is a handle of a handler subscribed to a broker, which replies to requests.
+type Responder[T any] struct {
+	requests *Broker[*request]
+	client   Client[*request]
+	done     chan void
+}
+
+// Respond subscribes a handler to the broker, which is called with every request sent to the broker by Request,
+// and whose reply (or error) is passed back to the requester. If multiple handlers are subscribed,
+// every request is handled by only one of them, in turn. Requests are independent of published messages.
+// Returns ErrTimeout on timeout, or ErrClosed if the broker is closed.
+func (broker *Broker[T]) Respond(handler func(T) (T, error)) (*Responder[T], error) {
+	requests, err := broker.requestBroker()
+	if err != nil {
+		return nil, err
+	}
+	client, err := requests.Subscribe(WithGroup(responderGroup))
+	if err != nil {
+		return nil, err
+	}
+	responder := &Responder[T]{requests: requests, client: client, done: make(chan void)}
+	go func() {
+		defer close(responder.done)
+		for req := range client {
+			// the message is nil if it is a nil interface
+			message, _ := req.message.(T)
+			reply, err := handler(message)
+			req.reply <- response{reply, err}
+		}
+	}()
+	return responder, nil
+}
+
+// Close unsubscribes the handler from the broker, and blocks until it handled the current request.
+// Returns ErrTimeout on timeout.
+func (responder *Responder[T]) Close() error {
+	select {
+	case <-responder.done:
+		return nil
+	default:
+	}
+	if err := responder.requests.Unsubscribe(responder.client); err != nil && !errors.Is(err, ErrClosed) {
+		return err
+	}
+	<-responder.done
+	return nil
+}
+
+// Request sends a request message to one of the responders of the broker, and blocks until it replies,
+// or the context is done. Returns the reply, or the error returned by the responder.
+// The request waits for a busy responder until the context is done, or until the timeout if it can never be done.
+// Returns ErrNoResponder if no responder is subscribed, ErrTimeout if the responder did not receive the request
+// before the timeout, ErrClosed if the broker is closed, or the context error if the context is done.
+func (broker *Broker[T]) Request(ctx context.Context, message T) (T, error) {
+	var reply T
+	requests, err := broker.requestBroker()
+	if err != nil {
+		return reply, err
+	}
+	req := &request{message: message, reply: make(chan response, 1)}
+	env := requests.wrap(req, publishOptions{})
+	outcome := make(chan Outcome, 1)
+	env.outcome = outcome
+	if ctx.Done() != nil {
+		env.ctx = ctx
+	}
+	if err := requests.send(ctx, &env, requests.deadline(ctx, requests.currentTimeout())); err != nil {
+		return reply, err
+	}
+	var result Outcome
+	select {
+	case result = <-outcome:
+	case <-requests.done:
+		// the outcome may be resolved by the broker loop before it terminated
+		select {
+		case result = <-outcome:
+		default:
+			return reply, broker.error(ErrClosed)
+		}
+	case <-ctx.Done():
+		// the broker discards the request when it is its turn
+		return reply, broker.contextError(ctx.Err())
+	}
+	switch {
+	case result.Err != nil:
+		return reply, broker.error(result.Err)
+	case result.Delivered == 0 && result.Dropped > 0:
+		return reply, broker.error(ErrTimeout)
+	case result.Delivered == 0:
+		return reply, broker.error(ErrNoResponder)
+	}
+	select {
+	case response := <-req.reply:
+		reply, _ = response.message.(T)
+		return reply, response.err
+	case <-ctx.Done():
+		return reply, broker.contextError(ctx.Err())
+	}
+}
+
+// requestBroker returns the broker carrying the requests to the responders, which is built on first use,
+// and closed when the broker is stopped. Returns ErrClosed if the broker is closed.
+func (broker *Broker[T]) requestBroker() (*Broker[*request], error) {
+	broker.requestsMutex.Lock()
+	defer broker.requestsMutex.Unlock()
+	if broker.closed.Load() {
+		return nil, broker.error(ErrClosed)
+	}
+	if broker.requests == nil {
+		requests := NewBuilder[*request]().Name(broker.name).Timeout(broker.currentTimeout()).Build()
+		go func() {
+			<-broker.stop
+			requests.Close()
+		}()
+		broker.requests = requests
+	}
+	return broker.requests, nil
+}
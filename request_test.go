@@ -0,0 +1,122 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[string]().Timeout(100 * time.Millisecond).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+	errEmpty := errors.New("empty request")
+	responder, err := broker.Respond(func(message string) (string, error) {
+		if message == "" {
+			return "", errEmpty
+		}
+		return strings.ToUpper(message), nil
+	})
+	assertions.Nil(err)
+
+	reply, err := broker.Request(context.Background(), "hello")
+	assertions.Nil(err)
+	assertions.Equal("HELLO", reply)
+	_, err = broker.Request(context.Background(), "")
+	assertions.ErrorIs(err, errEmpty)
+
+	// requests are not sent to the clients
+	select {
+	case <-client:
+		assertions.Fail("Received message not expected")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assertions.Nil(responder.Close())
+	assertions.Nil(responder.Close())
+	_, err = broker.Request(context.Background(), "hello")
+	assertions.ErrorIs(err, ErrNoResponder)
+
+	broker.Close()
+	_, err = broker.Request(context.Background(), "hello")
+	assertions.ErrorIs(err, ErrClosed)
+	_, err = broker.Respond(func(message string) (string, error) { return message, nil })
+	assertions.ErrorIs(err, ErrClosed)
+}
+
+func TestRequestResponders(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	for i := 1; i <= 2; i++ {
+		factor := i
+		_, err := broker.Respond(func(message int) (int, error) { return message * factor, nil })
+		assertions.Nil(err)
+	}
+
+	// every request is handled by one responder, in turn
+	var replies []int
+	for i := 0; i < 4; i++ {
+		reply, err := broker.Request(context.Background(), 10)
+		assertions.Nil(err)
+		replies = append(replies, reply)
+	}
+	assertions.ElementsMatch([]int{10, 10, 20, 20}, replies)
+
+	// the responders are unsubscribed when the broker is closed
+	broker.Close()
+}
+
+func TestRequestContext(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Name("request").Timeout(100 * time.Millisecond).Build()
+	release := make(chan void)
+	responder, err := broker.Respond(func(message int) (int, error) {
+		<-release
+		return message, nil
+	})
+	assertions.Nil(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = broker.Request(ctx, 1)
+	assertions.ErrorIs(err, context.DeadlineExceeded)
+	assertions.ErrorContains(err, `broker "request"`)
+
+	close(release)
+	assertions.Nil(responder.Close())
+	broker.Close()
+}
+
+func TestRequestBusyResponder(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(20 * time.Millisecond).Build()
+	_, err := broker.Respond(func(message int) (int, error) {
+		time.Sleep(50 * time.Millisecond)
+		return message, nil
+	})
+	assertions.Nil(err)
+
+	// the second request waits for the busy responder longer than the timeout, but not longer than the context
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	replies := make(chan int, 2)
+	for i := 1; i <= 2; i++ {
+		go func(message int) {
+			reply, err := broker.Request(ctx, message)
+			assertions.Nil(err)
+			replies <- reply
+		}(i)
+	}
+	assertions.ElementsMatch([]int{1, 2}, []int{<-replies, <-replies})
+
+	broker.Close()
+}
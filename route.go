@@ -0,0 +1,56 @@
+package broker
+
+// RouteKey is a key derived from the content of a message, by which the broker routes the message to the clients
+// subscribed with the key.
+type RouteKey string
+
+// RouteBy configures the broker to derive the route keys of every message by the function, so that the message
+// is only sent to the clients subscribed with one of its route keys (and to the clients subscribed without route keys).
+// This dispatches messages by their content, like the value of a field, without a filter for every client.
+func (builder Builder[T]) RouteBy(route func(T) []RouteKey) Builder[T] {
+	builder.route = route
+	return builder
+}
+
+// WithRouteKeys configures a client to only receive the messages with one of the route keys,
+// if the broker derives route keys from the messages.
+func WithRouteKeys(keys ...RouteKey) SubscribeOption {
+	return func(options *subscribeOptions) {
+		options.routes = append(options.routes, keys...)
+	}
+}
+
+// routeKeys returns the set of route keys of a client, or nil if the client is subscribed without route keys.
+func routeKeys(keys []RouteKey) map[RouteKey]void {
+	if len(keys) == 0 {
+		return nil
+	}
+	set := make(map[RouteKey]void, len(keys))
+	for _, key := range keys {
+		set[key] = void{}
+	}
+	return set
+}
+
+// routeOf derives the route keys of a message, if the broker routes messages.
+func (broker *Broker[T]) routeOf(env *envelope[T]) {
+	if broker.route != nil {
+		env.routes, env.routed = broker.route(env.message), true
+	}
+}
+
+// accepts reports whether a client accepts a message, because it matches its filter and its route keys.
+func (sub *subscriber[T]) accepts(env *envelope[T]) bool {
+	if sub.filter != nil && !sub.filter(env.message) {
+		return false
+	}
+	if !env.routed || sub.routes == nil {
+		return true
+	}
+	for _, key := range env.routes {
+		if _, ok := sub.routes[key]; ok {
+			return true
+		}
+	}
+	return false
+}
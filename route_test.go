@@ -0,0 +1,60 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type order struct {
+	region string
+	amount int
+}
+
+func TestRouteBy(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[order]().
+		Timeout(100 * time.Millisecond).
+		RouteBy(func(msg order) []RouteKey {
+			keys := []RouteKey{RouteKey(msg.region)}
+			if msg.amount > 100 {
+				keys = append(keys, "large")
+			}
+			return keys
+		}).
+		Build()
+	eu, err := broker.Subscribe(WithRouteKeys("eu"), WithBufferSize(10))
+	assertions.Nil(err)
+	large, err := broker.Subscribe(WithRouteKeys("large", "us"), WithBufferSize(10))
+	assertions.Nil(err)
+	all, err := broker.Subscribe(WithBufferSize(10))
+	assertions.Nil(err)
+
+	orders := []order{{"eu", 10}, {"us", 20}, {"eu", 200}, {"asia", 300}, {"asia", 30}}
+	for _, msg := range orders {
+		assertions.Nil(broker.PublishSync(msg))
+	}
+	assertions.Equal([]order{orders[0], orders[2]}, []order{<-eu, <-eu})
+	assertions.Equal([]order{orders[1], orders[2], orders[3]}, []order{<-large, <-large, <-large})
+	assertions.Len(all, len(orders))
+	assertions.Zero(len(eu))
+	assertions.Zero(len(large))
+
+	broker.Close()
+}
+
+func TestWithRouteKeysWithoutRouteBy(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	client, err := broker.Subscribe(WithRouteKeys("eu"), WithBufferSize(10))
+	assertions.Nil(err)
+
+	// the broker derives no route keys, so the client receives all messages
+	assertions.Nil(broker.PublishSync(1))
+	assertions.Equal(1, <-client)
+
+	broker.Close()
+}
@@ -0,0 +1,138 @@
+package broker
+
+import (
+	"errors"
+	"sync"
+)
+
+// Router republishes the messages of a source broker to named destination brokers, according to routing rules.
+type Router[T any] struct {
+	mutex        sync.RWMutex
+	destinations map[string]*Broker[T]
+	routes       []route[T]
+	errorHandler func(destination string, err error)
+	source       *Broker[T]
+	client       Client[T]
+	done         chan void
+}
+
+// route is a routing rule of a router.
+type route[T any] struct {
+	predicate    func(T) bool
+	destinations []string
+}
+
+// ErrUnknownDestination is the error passed to the error handler of a router,
+// when a message is routed to a destination that is not registered.
+var ErrUnknownDestination = errors.New("unknown destination")
+
+// NewRouter subscribes to the source broker and constructs a new router without any destinations and routes.
+// Returns ErrTimeout on timeout.
+func NewRouter[T any](source *Broker[T]) (*Router[T], error) {
+	client, err := source.Subscribe()
+	if err != nil {
+		return nil, err
+	}
+	router := &Router[T]{
+		destinations: make(map[string]*Broker[T]),
+		source:       source,
+		client:       client,
+		done:         make(chan void),
+	}
+	go router.run()
+	return router, nil
+}
+
+// Destination registers a destination broker under a name, replacing any destination registered under that name.
+// The router does not take ownership of the destination broker, it must still be closed by the caller.
+func (router *Router[T]) Destination(name string, destination *Broker[T]) *Router[T] {
+	router.mutex.Lock()
+	defer router.mutex.Unlock()
+	router.destinations[name] = destination
+	return router
+}
+
+// Route adds a routing rule, so that all messages matching the predicate are republished to the named destinations.
+// A message matching multiple routing rules is republished at most once to each destination.
+func (router *Router[T]) Route(predicate func(T) bool, destinations ...string) *Router[T] {
+	router.mutex.Lock()
+	defer router.mutex.Unlock()
+	router.routes = append(router.routes, route[T]{predicate, destinations})
+	return router
+}
+
+// replaceRoutes atomically replaces the destinations and routing rules of the router.
+func (router *Router[T]) replaceRoutes(destinations map[string]*Broker[T], routes []route[T]) {
+	router.mutex.Lock()
+	defer router.mutex.Unlock()
+	router.destinations = make(map[string]*Broker[T], len(destinations))
+	for name, destination := range destinations {
+		router.destinations[name] = destination
+	}
+	router.routes = routes
+}
+
+// OnError configures a handler for errors occurring when a message is republished to a destination,
+// like ErrUnknownDestination or ErrTimeout. By default, such errors are ignored.
+func (router *Router[T]) OnError(handler func(destination string, err error)) *Router[T] {
+	router.mutex.Lock()
+	defer router.mutex.Unlock()
+	router.errorHandler = handler
+	return router
+}
+
+// Close unsubscribes the router from the source broker.
+// Returns ErrTimeout on timeout.
+func (router *Router[T]) Close() error {
+	select {
+	case <-router.done:
+		return nil
+	default:
+	}
+	if err := router.source.Unsubscribe(router.client); err != nil {
+		return err
+	}
+	<-router.done
+	return nil
+}
+
+// run starts the router loop, which routes the messages of the source broker until it closes the client.
+func (router *Router[T]) run() {
+	defer close(router.done)
+	for msg := range router.client {
+		router.route(msg)
+	}
+}
+
+// route republishes a message to the destinations of all matching routing rules.
+func (router *Router[T]) route(msg T) {
+	router.mutex.RLock()
+	defer router.mutex.RUnlock()
+	routed := make(map[string]void)
+	for _, r := range router.routes {
+		if !r.predicate(msg) {
+			continue
+		}
+		for _, name := range r.destinations {
+			if _, ok := routed[name]; ok {
+				continue
+			}
+			routed[name] = void{}
+			destination, ok := router.destinations[name]
+			if !ok {
+				router.handleError(name, ErrUnknownDestination)
+				continue
+			}
+			if err := destination.Publish(msg); err != nil {
+				router.handleError(name, err)
+			}
+		}
+	}
+}
+
+// handleError passes an error to the error handler, if any.
+func (router *Router[T]) handleError(destination string, err error) {
+	if router.errorHandler != nil {
+		router.errorHandler(destination, err)
+	}
+}
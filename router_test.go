@@ -0,0 +1,73 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter(t *testing.T) {
+	assertions := assert.New(t)
+
+	source := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	even := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	large := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+
+	errs := make(chan error, 10)
+	router, err := NewRouter(source)
+	assertions.Nil(err)
+	router.
+		Destination("even", even).
+		Destination("large", large).
+		Route(func(msg int) bool { return msg%2 == 0 }, "even").
+		Route(func(msg int) bool { return msg > 10 }, "large", "even").
+		Route(func(msg int) bool { return msg < 0 }, "unknown").
+		OnError(func(destination string, err error) {
+			assertions.Equal("unknown", destination)
+			errs <- err
+		})
+
+	evenClient, err := even.Subscribe()
+	assertions.Nil(err)
+	largeClient, err := large.Subscribe()
+	assertions.Nil(err)
+
+	for _, msg := range []int{1, 2, 11, 12, -1} {
+		assertions.Nil(source.Publish(msg))
+	}
+
+	assertions.Equal(2, <-evenClient)
+	assertions.Equal(11, <-evenClient)
+	assertions.Equal(11, <-largeClient)
+	assertions.Equal(12, <-evenClient)
+	assertions.Equal(12, <-largeClient)
+	assertions.ErrorIs(<-errs, ErrUnknownDestination)
+
+	select {
+	case <-evenClient:
+		assertions.Fail("Received message not expected")
+	case <-largeClient:
+		assertions.Fail("Received message not expected")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	assertions.Nil(router.Close())
+	assertions.Nil(router.Close())
+
+	source.Close()
+	even.Close()
+	large.Close()
+}
+
+func TestNewRouterClosed(t *testing.T) {
+	assertions := assert.New(t)
+
+	source := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	source.Close()
+	time.Sleep(200 * time.Millisecond)
+
+	router, err := NewRouter(source)
+	assertions.Nil(router)
+	assertions.ErrorIs(err, ErrClosed)
+}
@@ -0,0 +1,165 @@
+package broker
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrScheduleFull is the error returned when a message is published for later,
+// but the broker holds the maximum number of scheduled messages already.
+var ErrScheduleFull = errors.New("schedule full")
+
+// PublishAt publishes a message with per-message options to the broker, which holds the message and broadcasts it
+// at the given time (or immediately, if the time passed already). The time to live of the message starts when
+// it is published, not when it is due. The message is held in addition to the buffered messages,
+// up to the maximum number of scheduled messages of the broker.
+// Returns ErrScheduleFull if the broker holds the maximum number of scheduled messages, ErrTimeout on timeout,
+// ErrClosed if the broker is closed, or ErrReadOnly if the broker is a mirror.
+func (broker *Broker[T]) PublishAt(at time.Time, message T, opts ...PublishOption) error {
+	if broker.readOnly {
+		return broker.error(ErrReadOnly)
+	}
+	if broker.scheduling.Add(1) > broker.maxScheduled {
+		broker.scheduling.Add(-1)
+		return broker.error(ErrScheduleFull)
+	}
+	options := publishOptions{timeout: broker.currentTimeout()}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	env := broker.wrap(message, options)
+	env.due = at
+	if err := broker.send(context.Background(), &env, time.After(options.timeout)); err != nil {
+		broker.scheduling.Add(-1)
+		return err
+	}
+	return nil
+}
+
+// PublishAfter publishes a message with per-message options to the broker like PublishAt,
+// which broadcasts the message after the given delay.
+// Returns ErrTimeout on timeout, ErrClosed if the broker is closed, or ErrReadOnly if the broker is a mirror.
+func (broker *Broker[T]) PublishAfter(delay time.Duration, message T, opts ...PublishOption) error {
+	return broker.PublishAt(time.Now().Add(delay), message, opts...)
+}
+
+// enqueue adds a published message to the pending messages, or to the scheduled messages if it is not due yet.
+// In conflation mode, the message replaces the pending message with the same key instead.
+func (broker *Broker[T]) enqueue(env envelope[T]) {
+	if !env.due.IsZero() {
+		if time.Now().Before(env.due) {
+			broker.scheduled.push(env)
+			return
+		}
+		broker.scheduling.Add(-1)
+	}
+	if broker.conflate(env) {
+		return
+	}
+	broker.pending.push(env)
+}
+
+// releaseDue moves the scheduled messages that are due to the pending messages.
+func (broker *Broker[T]) releaseDue(now time.Time) {
+	for broker.scheduled.Len() > 0 && !now.Before(broker.scheduled.envelopes[0].due) {
+		broker.pending.push(broker.scheduled.pop())
+		broker.scheduling.Add(-1)
+	}
+}
+
+// MaxScheduled configures the maximum number of messages published for later that the broker holds until they are
+// due, including the messages published for later that are still buffered. Defaults to 1000.
+func (builder Builder[T]) MaxScheduled(scheduled int) Builder[T] {
+	builder.scheduled = scheduled
+	return builder
+}
+
+// scheduledQueue holds the messages published for a later time, ordered by their due time
+// and publishing sequence. It implements heap.Interface.
+type scheduledQueue[T any] struct {
+	envelopes []envelope[T]
+	sequence  uint64
+	timer     *time.Timer
+	armed     time.Time
+}
+
+// Len implements heap.Interface.
+func (queue *scheduledQueue[T]) Len() int {
+	return len(queue.envelopes)
+}
+
+// Less implements heap.Interface.
+func (queue *scheduledQueue[T]) Less(i, j int) bool {
+	a, b := &queue.envelopes[i], &queue.envelopes[j]
+	if !a.due.Equal(b.due) {
+		return a.due.Before(b.due)
+	}
+	return a.sequence < b.sequence
+}
+
+// Swap implements heap.Interface.
+func (queue *scheduledQueue[T]) Swap(i, j int) {
+	queue.envelopes[i], queue.envelopes[j] = queue.envelopes[j], queue.envelopes[i]
+}
+
+// Push implements heap.Interface.
+func (queue *scheduledQueue[T]) Push(x any) {
+	queue.envelopes = append(queue.envelopes, x.(envelope[T]))
+}
+
+// Pop implements heap.Interface.
+func (queue *scheduledQueue[T]) Pop() any {
+	n := len(queue.envelopes) - 1
+	env := queue.envelopes[n]
+	queue.envelopes[n] = envelope[T]{}
+	queue.envelopes = queue.envelopes[:n]
+	return env
+}
+
+// push adds a message to the queue.
+func (queue *scheduledQueue[T]) push(env envelope[T]) {
+	queue.sequence++
+	env.sequence = queue.sequence
+	heap.Push(queue, env)
+}
+
+// pop removes the message that is due first from the queue.
+func (queue *scheduledQueue[T]) pop() envelope[T] {
+	return heap.Pop(queue).(envelope[T])
+}
+
+// wait returns a channel receiving when the message that is due first is due, or nil if the queue is empty.
+// The timer is only reset when another message is due first since the last call.
+func (queue *scheduledQueue[T]) wait() <-chan time.Time {
+	if queue.Len() == 0 {
+		return nil
+	}
+	due := queue.envelopes[0].due
+	if queue.timer == nil {
+		queue.timer = time.NewTimer(time.Until(due))
+	} else if !due.Equal(queue.armed) {
+		if !queue.timer.Stop() {
+			select {
+			case <-queue.timer.C:
+			default:
+			}
+		}
+		queue.timer.Reset(time.Until(due))
+	}
+	queue.armed = due
+	return queue.timer.C
+}
+
+// fired records that the timer fired, so that it is reset by the next wait.
+func (queue *scheduledQueue[T]) fired() {
+	queue.armed = time.Time{}
+}
+
+// stop stops the timer, if any.
+func (queue *scheduledQueue[T]) stop() {
+	if queue.timer != nil {
+		queue.timer.Stop()
+	}
+}
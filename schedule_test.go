@@ -0,0 +1,82 @@
+package broker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishAfter(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	start := time.Now()
+	assertions.Nil(broker.PublishAfter(200*time.Millisecond, 3))
+	assertions.Nil(broker.PublishAfter(100*time.Millisecond, 2))
+	assertions.Nil(broker.PublishAt(start.Add(-time.Second), 1))
+	assertions.Equal(1, <-client)
+	assertions.Equal(2, <-client)
+	assertions.GreaterOrEqual(time.Since(start), 100*time.Millisecond)
+	assertions.Equal(3, <-client)
+	assertions.GreaterOrEqual(time.Since(start), 200*time.Millisecond)
+
+	broker.Close()
+}
+
+func TestPublishAfterReadOnly(t *testing.T) {
+	assertions := assert.New(t)
+
+	source := New[int]()
+	mirror, err := NewMirror(source)
+	assertions.Nil(err)
+	assertions.ErrorIs(mirror.PublishAfter(time.Second, 1), ErrReadOnly)
+
+	source.Close()
+	assertions.Nil(mirror.WaitClosed(context.Background()))
+}
+
+func TestPublishAfterCloseWithContext(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	client, err := broker.Subscribe(WithBufferSize(1))
+	assertions.Nil(err)
+
+	// the graceful shutdown broadcasts the buffered messages, but drops the scheduled messages
+	assertions.Nil(broker.PublishAfter(time.Hour, 1))
+	assertions.Nil(broker.Publish(2))
+	start := time.Now()
+	assertions.Nil(broker.CloseWithContext(context.Background()))
+	assertions.Less(time.Since(start), time.Second)
+	assertions.Equal(2, <-client)
+	_, ok := <-client
+	assertions.False(ok)
+}
+
+func TestMaxScheduled(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Name("scheduler").Timeout(100 * time.Millisecond).MaxScheduled(2).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	assertions.Nil(broker.PublishAfter(time.Hour, 1))
+	assertions.Nil(broker.PublishAfter(50*time.Millisecond, 2))
+	err = broker.PublishAfter(time.Hour, 3)
+	assertions.ErrorIs(err, ErrScheduleFull)
+	assertions.EqualError(err, `broker "scheduler": schedule full`)
+
+	// a message leaves the schedule when it is due
+	assertions.Equal(2, <-client)
+	assertions.Nil(broker.PublishAt(time.Now(), 4))
+	assertions.Equal(4, <-client)
+	assertions.Nil(broker.PublishAfter(time.Hour, 5))
+	assertions.ErrorIs(broker.PublishAfter(time.Hour, 6), ErrScheduleFull)
+
+	broker.Close()
+}
@@ -0,0 +1,121 @@
+package broker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the counters of a broker.
+type Stats struct {
+	// Name is the name of the broker.
+	Name string
+	// Published is the number of messages accepted by the broker.
+	Published uint64
+	// Broadcasts is the number of messages broadcast to the clients.
+	Broadcasts uint64
+	// Delivered is the number of messages sent to clients.
+	Delivered uint64
+	// Dropped is the number of messages discarded because a client did not receive them in time.
+	Dropped uint64
+	// Expired is the number of messages discarded because their time to live elapsed before they were broadcast.
+	Expired uint64
+	// BroadcastTime is the total time spent broadcasting messages to the clients.
+	BroadcastTime time.Duration
+	// Subscribers is the number of clients currently subscribed to the broker.
+	Subscribers int
+	// Buffered is the number of messages currently published to the broker, but not broadcast yet.
+	Buffered int
+	// BufferSize is the current size of the message buffer.
+	BufferSize int
+	// OneMinuteRates are the rates of the counters averaged over one minute.
+	OneMinuteRates Rates
+	// FiveMinuteRates are the rates of the counters averaged over five minutes.
+	FiveMinuteRates Rates
+}
+
+// StatsSource defines a source of broker stats, which is implemented by every broker.
+type StatsSource interface {
+	Stats() Stats
+}
+
+// ResetSince reports whether the counters were reset with ResetStats since the previous stats were taken,
+// in which case the differences to the previous stats are meaningless.
+func (stats Stats) ResetSince(previous Stats) bool {
+	return stats.Published < previous.Published || stats.Broadcasts < previous.Broadcasts ||
+		stats.Delivered < previous.Delivered || stats.Dropped < previous.Dropped || stats.Expired < previous.Expired
+}
+
+// counters holds the counters of a broker, which are updated atomically.
+type counters struct {
+	published     atomic.Uint64
+	broadcasts    atomic.Uint64
+	delivered     atomic.Uint64
+	dropped       atomic.Uint64
+	expired       atomic.Uint64
+	broadcastTime atomic.Int64
+	subscribers   atomic.Int64
+
+	bufferedMessages atomic.Int64
+	bufferedBytes    atomic.Int64
+	historyMessages  atomic.Int64
+	historyBytes     atomic.Int64
+	clientCapacity   atomic.Int64
+}
+
+// Stats returns a snapshot of the counters of the broker.
+func (broker *Broker[T]) Stats() Stats {
+	broker.rates.mutex.Lock()
+	oneMinute, fiveMinutes := broker.rates.oneMinute, broker.rates.fiveMinutes
+	broker.rates.mutex.Unlock()
+	return Stats{
+		Name:            broker.name,
+		Published:       broker.counters.published.Load(),
+		Broadcasts:      broker.counters.broadcasts.Load(),
+		Delivered:       broker.counters.delivered.Load(),
+		Dropped:         broker.counters.dropped.Load(),
+		Expired:         broker.counters.expired.Load(),
+		BroadcastTime:   time.Duration(broker.counters.broadcastTime.Load()),
+		Subscribers:     int(broker.counters.subscribers.Load()),
+		Buffered:        int(broker.counters.bufferedMessages.Load()),
+		BufferSize:      broker.bufferSize(),
+		OneMinuteRates:  oneMinute,
+		FiveMinuteRates: fiveMinutes,
+	}
+}
+
+// bufferSize returns the current size of the message buffer.
+func (broker *Broker[T]) bufferSize() int {
+	return cap(broker.messages.Load().messages)
+}
+
+// StatsTopic configures the broker to publish a snapshot of its stats in the interval to a dedicated stats broker,
+// so that the health of the broker can be consumed by subscribing to the stats broker like to any other broker.
+// The stats broker is closed together with the broker.
+func (builder Builder[T]) StatsTopic(interval time.Duration) Builder[T] {
+	builder.stats = interval
+	return builder
+}
+
+// StatsBroker returns the broker to which the broker publishes snapshots of its stats,
+// or nil if the broker was not configured with a stats topic. The stats broker is closed together with the broker,
+// closing it earlier only stops the snapshots.
+func (broker *Broker[T]) StatsBroker() *Broker[Stats] {
+	return broker.statsBroker
+}
+
+// publishStats publishes a snapshot of the stats to the stats broker on every interval, until the broker is stopped.
+// A snapshot is discarded if the stats broker does not accept it in time.
+func (broker *Broker[T]) publishStats(interval time.Duration) {
+	// the stats broker may have been closed by its user already
+	defer broker.statsBroker.close(ErrClosed)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-broker.stop:
+			return
+		case <-ticker.C:
+			_ = broker.statsBroker.Publish(broker.Stats())
+		}
+	}
+}
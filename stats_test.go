@@ -0,0 +1,120 @@
+package broker
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(50 * time.Millisecond).Build()
+	assertions.Equal(Stats{BufferSize: defaultBufferSize}, broker.Stats())
+
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+	_, err = broker.Subscribe()
+	assertions.Nil(err)
+
+	assertions.Nil(broker.Publish(42))
+	assertions.Equal(42, <-client)
+
+	assertions.Eventually(func() bool {
+		return broker.Stats().Broadcasts == 1
+	}, time.Second, 10*time.Millisecond)
+
+	stats := broker.Stats()
+	assertions.Equal(uint64(1), stats.Published)
+	assertions.Equal(uint64(1), stats.Delivered)
+	assertions.Equal(uint64(1), stats.Dropped)
+	assertions.Equal(2, stats.Subscribers)
+	assertions.GreaterOrEqual(stats.BroadcastTime, 50*time.Millisecond)
+
+	assertions.Nil(broker.Unsubscribe(client))
+	assertions.Eventually(func() bool {
+		return broker.Stats().Subscribers == 1
+	}, time.Second, 10*time.Millisecond)
+
+	broker.Close()
+}
+
+func TestStatsRates(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+	start := time.Now()
+	broker.updateRates(start)
+	broker.counters.published.Add(300)
+	broker.counters.delivered.Add(600)
+	broker.updateRates(start.Add(time.Minute))
+
+	stats := broker.Stats()
+	assertions.InDelta(5*(1-math.Exp(-1)), stats.OneMinuteRates.Published, 1e-9)
+	assertions.InDelta(10*(1-math.Exp(-1)), stats.OneMinuteRates.Delivered, 1e-9)
+	assertions.InDelta(5*(1-math.Exp(-0.2)), stats.FiveMinuteRates.Published, 1e-9)
+	assertions.Zero(stats.OneMinuteRates.Dropped)
+
+	// without new messages, the rates decay
+	broker.updateRates(start.Add(2 * time.Minute))
+	assertions.Less(broker.Stats().OneMinuteRates.Published, stats.OneMinuteRates.Published)
+
+	broker.Close()
+}
+
+func TestResetStats(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+	assertions.Nil(broker.Publish(42))
+	assertions.Equal(42, <-client)
+	assertions.Eventually(func() bool {
+		return broker.Stats().Broadcasts == 1
+	}, time.Second, 10*time.Millisecond)
+	broker.updateRates(time.Now().Add(rateInterval))
+	previous := broker.Stats()
+	assertions.False(previous.ResetSince(previous))
+
+	broker.ResetStats()
+	assertions.Equal(Stats{Subscribers: 1, BufferSize: defaultBufferSize}, broker.Stats())
+	assertions.True(broker.Stats().ResetSince(previous))
+
+	broker.Close()
+}
+
+func TestStatsTopic(t *testing.T) {
+	assertions := assert.New(t)
+
+	plain := New[int]()
+	assertions.Nil(plain.StatsBroker())
+	plain.Close()
+
+	broker := NewBuilder[int]().Name("events").StatsTopic(10 * time.Millisecond).Build()
+	stats, err := broker.StatsBroker().Subscribe()
+	assertions.Nil(err)
+
+	assertions.Nil(broker.Publish(42))
+	assertions.Eventually(func() bool {
+		snapshot := <-stats
+		return snapshot.Name == "events" && snapshot.Published == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// the stats broker is closed together with the broker
+	broker.Close()
+	assertions.Eventually(func() bool {
+		_, ok := <-stats
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestStatsTopicClosedFirst(t *testing.T) {
+	broker := NewBuilder[int]().StatsTopic(10 * time.Millisecond).Build()
+	broker.StatsBroker().Close()
+	time.Sleep(20 * time.Millisecond)
+	broker.Close()
+	<-broker.Done()
+}
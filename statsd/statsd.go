@@ -0,0 +1,152 @@
+// Package statsd provides an emitter that periodically pushes the stats of a broker to a StatsD or DogStatsD endpoint.
+package statsd
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/mpe85/go-broker"
+)
+
+// Emitter pushes the stats of a broker to a StatsD endpoint on an interval.
+type Emitter struct {
+	source   broker.StatsSource
+	conn     net.Conn
+	prefix   string
+	tags     []string
+	named    bool
+	previous broker.Stats
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Builder encapsulates the construction of a new emitter.
+type Builder struct {
+	prefix   string
+	interval time.Duration
+	tags     []string
+	named    bool
+}
+
+// defaultPrefix specifies the default prefix of all metric names.
+const defaultPrefix = "broker"
+
+// defaultInterval specifies the default interval in which the stats are pushed.
+const defaultInterval = 10 * time.Second
+
+// NewBuilder constructs a new builder.
+func NewBuilder() Builder {
+	return Builder{prefix: defaultPrefix, interval: defaultInterval}
+}
+
+// Prefix configures the prefix of all metric names.
+func (builder Builder) Prefix(prefix string) Builder {
+	builder.prefix = prefix
+	return builder
+}
+
+// Interval configures the interval in which the stats are pushed, which must be positive.
+func (builder Builder) Interval(interval time.Duration) Builder {
+	builder.interval = interval
+	return builder
+}
+
+// Tags configures DogStatsD tags (like "env:prod") that are attached to all metrics.
+// Without tags, the metrics are pushed in plain StatsD syntax.
+func (builder Builder) Tags(tags ...string) Builder {
+	builder.tags = tags
+	return builder
+}
+
+// BrokerTag configures the emitter to attach the name of a named broker as DogStatsD tag "broker" to all metrics.
+func (builder Builder) BrokerTag() Builder {
+	builder.named = true
+	return builder
+}
+
+// Build builds a new emitter that pushes the stats of the source to the StatsD endpoint at the UDP address.
+// Returns broker.ErrInvalidConfig if the interval is not positive.
+func (builder Builder) Build(source broker.StatsSource, address string) (*Emitter, error) {
+	if builder.interval <= 0 {
+		return nil, fmt.Errorf("%w: non-positive interval %v", broker.ErrInvalidConfig, builder.interval)
+	}
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	emitter := &Emitter{
+		source: source,
+		conn:   conn,
+		prefix: builder.prefix,
+		tags:   builder.tags,
+		named:  builder.named,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go emitter.run(builder.interval)
+	return emitter, nil
+}
+
+// Close pushes the stats a last time and stops the emitter.
+// Panics when the emitter is already stopped.
+func (emitter *Emitter) Close() error {
+	close(emitter.stop)
+	<-emitter.done
+	return emitter.conn.Close()
+}
+
+// run starts the emitter loop.
+func (emitter *Emitter) run(interval time.Duration) {
+	defer close(emitter.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-emitter.stop:
+			emitter.emit()
+			return
+		case <-ticker.C:
+			emitter.emit()
+		}
+	}
+}
+
+// emit pushes the counters accumulated since the previous push, and the current gauges.
+func (emitter *Emitter) emit() {
+	stats := emitter.source.Stats()
+	previous := emitter.previous
+	emitter.previous = stats
+	// start from zero if the counters were reset since the previous push
+	if stats.ResetSince(previous) {
+		previous = broker.Stats{}
+	}
+
+	tags := emitter.tags
+	if emitter.named && stats.Name != "" {
+		tags = append(append([]string(nil), tags...), "broker:"+stats.Name)
+	}
+	suffix := ""
+	if len(tags) > 0 {
+		suffix = "|#" + strings.Join(tags, ",")
+	}
+
+	var lines []string
+	metric := func(name string, value any, kind string) {
+		lines = append(lines, fmt.Sprintf("%s.%s:%v|%s%s", emitter.prefix, name, value, kind, suffix))
+	}
+	metric("published", stats.Published-previous.Published, "c")
+	metric("broadcasts", stats.Broadcasts-previous.Broadcasts, "c")
+	metric("delivered", stats.Delivered-previous.Delivered, "c")
+	metric("dropped", stats.Dropped-previous.Dropped, "c")
+	metric("expired", stats.Expired-previous.Expired, "c")
+	metric("subscribers", stats.Subscribers, "g")
+	if broadcasts := stats.Broadcasts - previous.Broadcasts; broadcasts > 0 {
+		average := (stats.BroadcastTime - previous.BroadcastTime) / time.Duration(broadcasts)
+		metric("broadcast_time", float64(average)/float64(time.Millisecond), "ms")
+	}
+
+	// errors are ignored, as StatsD is fire and forget
+	_, _ = emitter.conn.Write([]byte(strings.Join(lines, "\n")))
+}
@@ -0,0 +1,141 @@
+package statsd
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpe85/go-broker"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+type staticSource broker.Stats
+
+func (source staticSource) Stats() broker.Stats {
+	return broker.Stats(source)
+}
+
+func TestNewBuilder(t *testing.T) {
+	assertions := assert.New(t)
+
+	builder := NewBuilder()
+	assertions.Equal(defaultPrefix, builder.prefix)
+	assertions.Equal(defaultInterval, builder.interval)
+	assertions.Empty(builder.tags)
+}
+
+func TestEmitter(t *testing.T) {
+	assertions := assert.New(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assertions.Nil(err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	source := staticSource{
+		Name:          "events",
+		Published:     3,
+		Broadcasts:    2,
+		Delivered:     4,
+		Dropped:       1,
+		Expired:       5,
+		BroadcastTime: 4 * time.Millisecond,
+		Subscribers:   2,
+	}
+	emitter, err := NewBuilder().
+		Prefix("test").
+		Interval(50*time.Millisecond).
+		Tags("env:dev", "team:core").
+		BrokerTag().
+		Build(source, conn.LocalAddr().String())
+	assertions.Nil(err)
+
+	buffer := make([]byte, 1024)
+	assertions.Nil(conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFrom(buffer)
+	assertions.Nil(err)
+	assertions.Equal([]string{
+		"test.published:3|c|#env:dev,team:core,broker:events",
+		"test.broadcasts:2|c|#env:dev,team:core,broker:events",
+		"test.delivered:4|c|#env:dev,team:core,broker:events",
+		"test.dropped:1|c|#env:dev,team:core,broker:events",
+		"test.expired:5|c|#env:dev,team:core,broker:events",
+		"test.subscribers:2|g|#env:dev,team:core,broker:events",
+		"test.broadcast_time:2|ms|#env:dev,team:core,broker:events",
+	}, strings.Split(string(buffer[:n]), "\n"))
+
+	n, _, err = conn.ReadFrom(buffer)
+	assertions.Nil(err)
+	assertions.Equal([]string{
+		"test.published:0|c|#env:dev,team:core,broker:events",
+		"test.broadcasts:0|c|#env:dev,team:core,broker:events",
+		"test.delivered:0|c|#env:dev,team:core,broker:events",
+		"test.dropped:0|c|#env:dev,team:core,broker:events",
+		"test.expired:0|c|#env:dev,team:core,broker:events",
+		"test.subscribers:2|g|#env:dev,team:core,broker:events",
+	}, strings.Split(string(buffer[:n]), "\n"))
+
+	assertions.Nil(emitter.Close())
+	assertions.Panics(func() { _ = emitter.Close() })
+}
+
+func TestEmitterWithoutTags(t *testing.T) {
+	assertions := assert.New(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assertions.Nil(err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	// the name of the broker is only attached as tag on demand
+	emitter, err := NewBuilder().Build(staticSource{Name: "events", Subscribers: 1}, conn.LocalAddr().String())
+	assertions.Nil(err)
+	assertions.Nil(emitter.Close())
+
+	buffer := make([]byte, 1024)
+	assertions.Nil(conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFrom(buffer)
+	assertions.Nil(err)
+	assertions.Contains(strings.Split(string(buffer[:n]), "\n"), "broker.subscribers:1|g")
+}
+
+func TestEmitterInvalidAddress(t *testing.T) {
+	assertions := assert.New(t)
+
+	emitter, err := NewBuilder().Build(staticSource{}, "invalid address")
+	assertions.Nil(emitter)
+	assertions.Error(err)
+}
+
+func TestEmitterInvalidInterval(t *testing.T) {
+	assertions := assert.New(t)
+
+	emitter, err := NewBuilder().Interval(0).Build(staticSource{}, "127.0.0.1:8125")
+	assertions.Nil(emitter)
+	assertions.ErrorIs(err, broker.ErrInvalidConfig)
+}
+
+func TestEmitterReset(t *testing.T) {
+	assertions := assert.New(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assertions.Nil(err)
+	t.Cleanup(func() { _ = conn.Close() })
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	assertions.Nil(err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	// the counters of the source were reset since the previous push
+	emitter := &Emitter{source: staticSource{Published: 2}, conn: client, prefix: "test", previous: broker.Stats{Published: 5}}
+	emitter.emit()
+
+	buffer := make([]byte, 1024)
+	assertions.Nil(conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFrom(buffer)
+	assertions.Nil(err)
+	assertions.Contains(strings.Split(string(buffer[:n]), "\n"), "test.published:2|c")
+}
@@ -0,0 +1,100 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotSubscribed is the error returned when a client is not subscribed to the broker.
+var ErrNotSubscribed = errors.New("client not subscribed")
+
+// SubscriberState describes the delivery state of a client.
+type SubscriberState int
+
+const (
+	// Active means that the client received the last message sent to it, or that no message was sent to it yet.
+	Active SubscriberState = iota
+	// Lagging means that the client did not receive the last message sent to it in time, so that it was dropped.
+	Lagging
+	// Throttled means that the client subscribed with credits has no credits left,
+	// so that messages are dropped for it until it grants new credits.
+	Throttled
+	// Evicted means that the broker evicted the client, as it missed too many consecutive messages.
+	// The status of an evicted client is reported once, afterwards the client is not subscribed anymore.
+	Evicted
+)
+
+// String returns the name of the state.
+func (state SubscriberState) String() string {
+	switch state {
+	case Active:
+		return "active"
+	case Lagging:
+		return "lagging"
+	case Throttled:
+		return "throttled"
+	case Evicted:
+		return "evicted"
+	default:
+		return fmt.Sprintf("SubscriberState(%d)", int(state))
+	}
+}
+
+// SubscriberStatus is a snapshot of the delivery status of a single client.
+type SubscriberStatus struct {
+	// State is the delivery state of the client.
+	State SubscriberState
+	// QueueDepth is the number of messages sent to the client, but not received by it yet.
+	QueueDepth int
+	// Delivered is the number of messages sent to the client.
+	Delivered uint64
+	// Dropped is the number of messages discarded because the client did not receive them in time.
+	Dropped uint64
+	// Credits is the number of messages the broker may still send to a client subscribed with credits.
+	Credits int
+	// LastDelivery is the time the client received the last message, or the zero time if it received none yet.
+	LastDelivery time.Time
+}
+
+// statusRequest carries a request for the status of a client to the broker loop.
+type statusRequest[T any] struct {
+	client Client[T]
+	reply  chan *SubscriberStatus
+}
+
+// SubscriberStatus returns a snapshot of the delivery status of a client.
+// For a client evicted by the broker, the status at its eviction is returned once.
+// Returns ErrNotSubscribed if the client is not subscribed to the broker, ErrTimeout on timeout,
+// or ErrClosed if the broker is closed.
+func (broker *Broker[T]) SubscriberStatus(client Client[T]) (SubscriberStatus, error) {
+	request := statusRequest[T]{client: client, reply: make(chan *SubscriberStatus, 1)}
+	select {
+	case broker.statusRequests <- request:
+	case <-time.After(broker.currentTimeout()):
+		return SubscriberStatus{}, broker.error(ErrTimeout)
+	case <-broker.stop:
+		return SubscriberStatus{}, broker.error(ErrClosed)
+	}
+	if status := <-request.reply; status != nil {
+		return *status, nil
+	}
+	return SubscriberStatus{}, broker.error(ErrNotSubscribed)
+}
+
+// status returns a snapshot of the delivery status of a client.
+func (sub *subscriber[T]) status(client Client[T]) *SubscriberStatus {
+	status := &SubscriberStatus{
+		QueueDepth:   len(client),
+		Delivered:    sub.delivered,
+		Dropped:      sub.dropped,
+		Credits:      sub.credits,
+		LastDelivery: sub.lastDelivery,
+	}
+	if sub.credited && sub.credits == 0 {
+		status.State = Throttled
+	} else if sub.lagging {
+		status.State = Lagging
+	}
+	return status
+}
@@ -0,0 +1,68 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriberStatus(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(50 * time.Millisecond).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	status, err := broker.SubscriberStatus(client)
+	assertions.Nil(err)
+	assertions.Equal(SubscriberStatus{}, status)
+
+	before := time.Now()
+	assertions.Nil(broker.Publish(1))
+	assertions.Equal(1, <-client)
+	assertions.Eventually(func() bool {
+		status, err = broker.SubscriberStatus(client)
+		return err == nil && status.Delivered == 1
+	}, time.Second, 10*time.Millisecond)
+	assertions.Equal(Active, status.State)
+	assertions.False(status.LastDelivery.Before(before))
+
+	// the client does not receive the message, so that it is dropped
+	assertions.Nil(broker.Publish(2))
+	assertions.Eventually(func() bool {
+		status, err = broker.SubscriberStatus(client)
+		return err == nil && status.Dropped == 1
+	}, time.Second, 10*time.Millisecond)
+	assertions.Equal(Lagging, status.State)
+	assertions.Equal(uint64(1), status.Delivered)
+
+	assertions.Nil(broker.Unsubscribe(client))
+	_, err = broker.SubscriberStatus(client)
+	assertions.ErrorIs(err, ErrNotSubscribed)
+
+	// the client subscribed with credits spent its last credit
+	credited, err := broker.SubscribeWithCredits(1)
+	assertions.Nil(err)
+	assertions.Nil(broker.Publish(3))
+	assertions.Equal(3, <-credited)
+	status, err = broker.SubscriberStatus(credited)
+	assertions.Nil(err)
+	assertions.Equal(Throttled, status.State)
+	assertions.Nil(broker.Grant(credited, 1))
+	status, err = broker.SubscriberStatus(credited)
+	assertions.Nil(err)
+	assertions.Equal(Active, status.State)
+
+	broker.Close()
+}
+
+func TestSubscriberStateString(t *testing.T) {
+	assertions := assert.New(t)
+
+	assertions.Equal("active", Active.String())
+	assertions.Equal("lagging", Lagging.String())
+	assertions.Equal("throttled", Throttled.String())
+	assertions.Equal("evicted", Evicted.String())
+	assertions.Equal("SubscriberState(42)", SubscriberState(42).String())
+}
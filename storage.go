@@ -0,0 +1,112 @@
+package broker
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNoStorage is the error returned when messages are read from a broker without storage.
+var ErrNoStorage = errors.New("no storage configured")
+
+// Storage defines a store of the messages broadcast by a broker, in the order they were broadcast.
+// Every stored message is identified by its offset, which increases monotonically by one per message.
+// Storages must be safe for concurrent use.
+type Storage[T any] interface {
+	// Append stores a message, and returns its offset.
+	Append(message T) (uint64, error)
+	// ReadFrom returns up to max stored messages, starting with the message at the offset,
+	// or the oldest stored message if the message at the offset was trimmed already.
+	// Returns the offset of the first returned message, or the offset to read from again if no message is returned,
+	// which is the case if the offset is beyond the latest message or max is not positive.
+	ReadFrom(offset uint64, max int) ([]T, uint64, error)
+	// Trim removes all stored messages before the offset.
+	Trim(offset uint64) error
+}
+
+// MemoryStorage is a storage that keeps the messages in memory, so they do not survive restarts of the process.
+type MemoryStorage[T any] struct {
+	mutex    sync.Mutex
+	first    uint64
+	messages []T
+}
+
+// NewMemoryStorage constructs a new empty memory storage.
+func NewMemoryStorage[T any]() *MemoryStorage[T] {
+	return &MemoryStorage[T]{}
+}
+
+// Append implements Storage.
+func (storage *MemoryStorage[T]) Append(message T) (uint64, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	storage.messages = append(storage.messages, message)
+	return storage.first + uint64(len(storage.messages)) - 1, nil
+}
+
+// ReadFrom implements Storage.
+func (storage *MemoryStorage[T]) ReadFrom(offset uint64, max int) ([]T, uint64, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	if offset < storage.first {
+		offset = storage.first
+	}
+	start := offset - storage.first
+	if max <= 0 || start >= uint64(len(storage.messages)) {
+		return nil, offset, nil
+	}
+	messages := storage.messages[start:]
+	if len(messages) > max {
+		messages = messages[:max]
+	}
+	return append([]T(nil), messages...), offset, nil
+}
+
+// Trim implements Storage.
+func (storage *MemoryStorage[T]) Trim(offset uint64) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	if offset <= storage.first {
+		return nil
+	}
+	n := offset - storage.first
+	if n > uint64(len(storage.messages)) {
+		n = uint64(len(storage.messages))
+	}
+	storage.messages = append([]T(nil), storage.messages[n:]...)
+	storage.first += n
+	return nil
+}
+
+// Storage configures a storage to which the broker appends every message before it is broadcast,
+// so that the messages can be read again later, e.g. after a restart with a persistent storage.
+// A message that cannot be stored is discarded instead of broadcast.
+func (builder Builder[T]) Storage(storage Storage[T]) Builder[T] {
+	builder.storage = storage
+	return builder
+}
+
+// store appends a message to the storage of the broker, if any.
+func (broker *Broker[T]) store(env *envelope[T]) error {
+	if broker.storage == nil {
+		return nil
+	}
+	_, err := broker.storage.Append(env.message)
+	return err
+}
+
+// Read pulls up to max stored messages from the storage of the broker, starting with the message at the offset,
+// so that consumers can process batches of messages at their own pace instead of being sent every message.
+// Messages trimmed from the storage already are skipped. Returns the offset to read the following messages from,
+// which is the offset itself if no message is stored from it yet or max is not positive.
+// Messages can still be read after the broker closed.
+// Returns ErrNoStorage if the broker has no storage, or the error returned by the storage.
+func (broker *Broker[T]) Read(fromOffset uint64, max int) ([]T, uint64, error) {
+	if broker.storage == nil {
+		return nil, fromOffset, broker.error(ErrNoStorage)
+	}
+	messages, first, err := broker.storage.ReadFrom(fromOffset, max)
+	if err != nil {
+		return nil, fromOffset, broker.error(err)
+	}
+	return messages, first + uint64(len(messages)), nil
+}
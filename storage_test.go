@@ -0,0 +1,128 @@
+package broker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingStorage struct {
+	*MemoryStorage[int]
+}
+
+func (failingStorage) Append(int) (uint64, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestMemoryStorage(t *testing.T) {
+	assertions := assert.New(t)
+
+	storage := NewMemoryStorage[int]()
+	for msg := 1; msg <= 4; msg++ {
+		offset, err := storage.Append(msg)
+		assertions.Nil(err)
+		assertions.Equal(uint64(msg-1), offset)
+	}
+	messages, _, err := storage.ReadFrom(1, 2)
+	assertions.Nil(err)
+	assertions.Equal([]int{2, 3}, messages)
+	messages, _, err = storage.ReadFrom(4, 2)
+	assertions.Nil(err)
+	assertions.Empty(messages)
+	messages, first, err := storage.ReadFrom(1, -1)
+	assertions.Nil(err)
+	assertions.Empty(messages)
+	assertions.Equal(uint64(1), first)
+
+	assertions.Nil(storage.Trim(2))
+	messages, first, err = storage.ReadFrom(0, 10)
+	assertions.Nil(err)
+	assertions.Equal([]int{3, 4}, messages)
+	assertions.Equal(uint64(2), first)
+	offset, err := storage.Append(5)
+	assertions.Nil(err)
+	assertions.Equal(uint64(4), offset)
+
+	assertions.Nil(storage.Trim(1))
+	assertions.Nil(storage.Trim(10))
+	messages, _, err = storage.ReadFrom(0, 10)
+	assertions.Nil(err)
+	assertions.Empty(messages)
+}
+
+func TestStorage(t *testing.T) {
+	assertions := assert.New(t)
+
+	storage := NewMemoryStorage[int]()
+	broker := NewBuilder[int]().Storage(storage).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	assertions.Nil(broker.Publish(1))
+	assertions.Nil(broker.Publish(2))
+	assertions.Equal(1, <-client)
+	assertions.Equal(2, <-client)
+	messages, _, err := storage.ReadFrom(0, 10)
+	assertions.Nil(err)
+	assertions.Equal([]int{1, 2}, messages)
+
+	broker.Close()
+}
+
+func TestStorageFailure(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Storage(failingStorage{NewMemoryStorage[int]()}).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// the message is not broadcast if it cannot be stored
+	assertions.EqualError(broker.PublishSync(1), "disk full")
+	select {
+	case <-client:
+		assertions.Fail("Received message not expected")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	broker.Close()
+}
+
+func TestRead(t *testing.T) {
+	assertions := assert.New(t)
+
+	storage := NewMemoryStorage[int]()
+	broker := NewBuilder[int]().Storage(storage).Build()
+	for msg := 1; msg <= 5; msg++ {
+		assertions.Nil(broker.PublishSync(msg))
+	}
+
+	messages, next, err := broker.Read(0, 2)
+	assertions.Nil(err)
+	assertions.Equal([]int{1, 2}, messages)
+	assertions.Equal(uint64(2), next)
+	assertions.Nil(storage.Trim(3))
+	messages, next, err = broker.Read(next, 2)
+	assertions.Nil(err)
+	assertions.Equal([]int{4, 5}, messages)
+	assertions.Equal(uint64(5), next)
+
+	broker.Close()
+	messages, next, err = broker.Read(next, 2)
+	assertions.Nil(err)
+	assertions.Empty(messages)
+	assertions.Equal(uint64(5), next)
+}
+
+func TestReadNoStorage(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Name("pull").Build()
+	messages, next, err := broker.Read(3, 10)
+	assertions.Nil(messages)
+	assertions.Equal(uint64(3), next)
+	assertions.ErrorIs(err, ErrNoStorage)
+
+	broker.Close()
+}
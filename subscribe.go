@@ -0,0 +1,131 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrFilterType is the error returned when a client subscribes with a filter that does not accept the message type
+// of the broker.
+var ErrFilterType = errors.New("filter does not accept the message type")
+
+// SubscribeOption configures the subscription of a client.
+type SubscribeOption func(*subscribeOptions)
+
+// subscribeOptions holds the per-subscription configuration applied by subscribe options.
+type subscribeOptions struct {
+	catchUp    bool
+	bufferSize int
+	overflow   Overflow
+	replay     int
+	group      string
+	filter     any
+	routes     []RouteKey
+}
+
+// Overflow describes what the broker does with a message when a client cannot receive it immediately.
+type Overflow int
+
+const (
+	// Block means that the broker waits for the client until the timeout, and discards the message afterwards.
+	Block Overflow = iota
+	// DropNewest means that the broker discards the message immediately.
+	DropNewest
+	// DropOldest means that the broker discards the oldest message in the buffer of the client to make space for
+	// the message. For unbuffered clients, the broker discards the message immediately like with DropNewest.
+	DropOldest
+	// CloseSubscriber means that the broker discards the message immediately, and unsubscribes and closes the client.
+	CloseSubscriber
+)
+
+// String returns the name of the overflow policy.
+func (overflow Overflow) String() string {
+	switch overflow {
+	case Block:
+		return "block"
+	case DropNewest:
+		return "drop newest"
+	case DropOldest:
+		return "drop oldest"
+	case CloseSubscriber:
+		return "close subscriber"
+	default:
+		return fmt.Sprintf("Overflow(%d)", int(overflow))
+	}
+}
+
+// WithCatchUp configures a client to receive all messages published before the subscription that were not
+// broadcast yet, including the message the broker is currently broadcasting to other clients.
+// Otherwise, a client subscribing during a broadcast may miss the message being broadcast.
+func WithCatchUp() SubscribeOption {
+	return func(options *subscribeOptions) {
+		options.catchUp = true
+	}
+}
+
+// WithBufferSize configures the buffer size of a client, so that the broker does not wait for the client
+// while it has space left in its buffer. This absorbs brief stalls of a client, instead of dropping messages for it.
+// By default, clients are not buffered.
+func WithBufferSize(bufferSize int) SubscribeOption {
+	return func(options *subscribeOptions) {
+		options.bufferSize = bufferSize
+	}
+}
+
+// WithOverflow configures what the broker does with a message when the client cannot receive it immediately.
+// By default, the broker blocks until the timeout.
+func WithOverflow(overflow Overflow) SubscribeOption {
+	return func(options *subscribeOptions) {
+		options.overflow = overflow
+	}
+}
+
+// WithFilter configures a client to only receive the messages matching the filter, which the broker evaluates
+// before sending a message, so that the client does not spend buffer space or broker timeouts on other messages.
+// Unlike SetFilter, the filter applies from the first message on. The filter can be replaced by SetFilter later.
+// Subscribing fails with ErrFilterType if the filter does not accept the message type of the broker.
+func WithFilter[T any](filter func(T) bool) SubscribeOption {
+	return func(options *subscribeOptions) {
+		options.filter = filter
+	}
+}
+
+// Subscription is a handle of a client subscribed to a broker, which manages the lifecycle of the client.
+// Unlike a client, it only exposes the receiving end of the client.
+type Subscription[T any] struct {
+	broker *Broker[T]
+	client Client[T]
+}
+
+// NewSubscription registers a new client to the broker and returns its subscription.
+// Returns ErrTimeout on timeout, or ErrClosed if the broker is closed.
+func NewSubscription[T any](broker *Broker[T], opts ...SubscribeOption) (*Subscription[T], error) {
+	client, err := broker.Subscribe(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Subscription[T]{broker: broker, client: client}, nil
+}
+
+// C returns the channel receiving the messages of the subscription, which is closed after unsubscribing.
+func (subscription *Subscription[T]) C() <-chan T {
+	return subscription.client
+}
+
+// Unsubscribe removes the client of the subscription from the broker, and blocks until the broker closed it.
+// Returns ErrTimeout on timeout, or ErrClosed if the broker is closed.
+func (subscription *Subscription[T]) Unsubscribe() error {
+	return subscription.broker.Unsubscribe(subscription.client)
+}
+
+// SetFilter atomically replaces the filter of the subscription, so that only messages matching the filter
+// are sent to it. A nil filter removes the filter. Returns ErrTimeout on timeout, or ErrClosed if the broker is closed.
+func (subscription *Subscription[T]) SetFilter(filter func(T) bool) error {
+	return subscription.broker.SetFilter(subscription.client, filter)
+}
+
+// CloseReason returns the reason why the broker of the subscription stopped, or nil if it is not stopped,
+// like Broker.CloseReason.
+func (subscription *Subscription[T]) CloseReason() error {
+	return subscription.broker.CloseReason()
+}
@@ -0,0 +1,119 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscription(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	subscription, err := NewSubscription(broker)
+	assertions.Nil(err)
+
+	assertions.Nil(broker.Publish(42))
+	assertions.Equal(42, <-subscription.C())
+	assertions.Nil(subscription.SetFilter(func(msg int) bool { return msg%2 == 0 }))
+	assertions.Nil(broker.Publish(43))
+	assertions.Nil(broker.Publish(44))
+	assertions.Equal(44, <-subscription.C())
+
+	assertions.Nil(subscription.Unsubscribe())
+	_, ok := <-subscription.C()
+	assertions.False(ok)
+	assertions.Nil(subscription.CloseReason())
+
+	broker.Close()
+	assertions.ErrorIs(subscription.CloseReason(), ErrClosed)
+
+	subscription, err = NewSubscription(broker)
+	assertions.Nil(subscription)
+	assertions.ErrorIs(err, ErrClosed)
+}
+
+func TestSubscribeWithBufferSize(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(10 * time.Millisecond).Build()
+	client, err := broker.Subscribe(WithBufferSize(2))
+	assertions.Nil(err)
+	assertions.Equal(2, cap(client))
+
+	// the broker does not wait for the client while it has space left in its buffer
+	for msg := 1; msg <= 3; msg++ {
+		assertions.Nil(broker.Publish(msg))
+	}
+	assertions.Eventually(func() bool {
+		return broker.Stats().Broadcasts == 3
+	}, time.Second, 10*time.Millisecond)
+	assertions.Equal(uint64(2), broker.Stats().Delivered)
+	assertions.Equal(uint64(1), broker.Stats().Dropped)
+	assertions.Equal(1, <-client)
+	assertions.Equal(2, <-client)
+
+	broker.Close()
+}
+
+func TestSubscribeWithOverflow(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(time.Minute).Build()
+	newest, err := broker.Subscribe(WithBufferSize(2), WithOverflow(DropNewest))
+	assertions.Nil(err)
+	oldest, err := broker.Subscribe(WithBufferSize(2), WithOverflow(DropOldest))
+	assertions.Nil(err)
+	closing, err := broker.Subscribe(WithBufferSize(2), WithOverflow(CloseSubscriber))
+	assertions.Nil(err)
+
+	// the broker does not wait for the clients, although the timeout is long
+	for msg := 1; msg <= 3; msg++ {
+		assertions.Nil(broker.Publish(msg))
+	}
+	assertions.Eventually(func() bool {
+		return broker.Stats().Broadcasts == 3
+	}, time.Second, 10*time.Millisecond)
+
+	assertions.Equal([]int{1, 2}, []int{<-newest, <-newest})
+	assertions.Equal([]int{2, 3}, []int{<-oldest, <-oldest})
+	assertions.Equal([]int{1, 2}, []int{<-closing, <-closing})
+	_, ok := <-closing
+	assertions.False(ok)
+	assertions.Equal(2, broker.Stats().Subscribers)
+	assertions.Nil(broker.Unsubscribe(closing))
+
+	broker.Close()
+}
+
+func TestOverflowString(t *testing.T) {
+	assertions := assert.New(t)
+
+	assertions.Equal("block", Block.String())
+	assertions.Equal("drop newest", DropNewest.String())
+	assertions.Equal("drop oldest", DropOldest.String())
+	assertions.Equal("close subscriber", CloseSubscriber.String())
+	assertions.Equal("Overflow(42)", Overflow(42).String())
+}
+
+func TestWithFilter(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	even, err := broker.Subscribe(WithFilter(func(msg int) bool { return msg%2 == 0 }), WithBufferSize(10))
+	assertions.Nil(err)
+
+	for msg := 1; msg <= 4; msg++ {
+		assertions.Nil(broker.PublishSync(msg))
+	}
+	assertions.Equal([]int{2, 4}, []int{<-even, <-even})
+	assertions.Zero(len(even))
+
+	client, err := broker.Subscribe(WithFilter(func(string) bool { return true }))
+	assertions.Nil(client)
+	assertions.ErrorIs(err, ErrFilterType)
+	assertions.ErrorContains(err, "func(string) bool does not accept int")
+
+	broker.Close()
+}
@@ -0,0 +1,52 @@
+package broker
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotDelivered is the error returned when a message broadcast synchronously did not reach all its clients.
+var ErrNotDelivered = errors.New("message not delivered to all clients")
+
+// BroadcastSync publishes a message to the broker, and blocks until all clients received it.
+// Instead of the broker timeout, the broker waits for each client until the context is done,
+// which delays all other messages, so this is meant for rare events like configuration changes.
+// Clients that are not waited for, because of their overflow policy or for lack of credits, may drop the message.
+// Returns ErrClosed if the broker is closed, ErrReadOnly if the broker is a mirror,
+// the context error if the context is done before all clients received the message,
+// or ErrNotDelivered if any client dropped the message.
+func (broker *Broker[T]) BroadcastSync(ctx context.Context, message T) error {
+	if broker.readOnly {
+		return broker.error(ErrReadOnly)
+	}
+	if broker.closed.Load() {
+		return broker.error(ErrClosed)
+	}
+	env := broker.wrap(message, publishOptions{})
+	env.ctx = ctx
+	env.result = make(chan error, 1)
+	if err := broker.send(ctx, &env, nil); err != nil {
+		return err
+	}
+	select {
+	case err := <-env.result:
+		if err != nil && err == ctx.Err() {
+			// the context was done while the broker waited for a client
+			return broker.contextError(err)
+		}
+		return broker.error(err)
+	case <-broker.done:
+		return broker.error(ErrClosed)
+	case <-ctx.Done():
+		return broker.contextError(ctx.Err())
+	}
+}
+
+// PublishSync publishes a message with per-message options to the broker, and blocks until the broker sent it
+// to every client, or gave up on clients that did not receive it in time. Unlike BroadcastSync,
+// the broker waits for each client only until the broker timeout, so this does not delay other messages.
+// Returns ErrTimeout on timeout, ErrClosed if the broker is closed, or ErrReadOnly if the broker is a mirror.
+func (broker *Broker[T]) PublishSync(message T, opts ...PublishOption) error {
+	_, _, err := broker.PublishCounted(message, opts...)
+	return err
+}
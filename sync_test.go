@@ -0,0 +1,120 @@
+package broker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroadcastSync(t *testing.T) {
+	assertions := assert.New(t)
+
+	// the clients receive later than the broker timeout, which is not applied to synchronous broadcasts
+	broker := NewBuilder[int]().Timeout(10 * time.Millisecond).Build()
+	received := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		client, err := broker.Subscribe()
+		assertions.Nil(err)
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			received <- <-client
+		}()
+	}
+
+	assertions.Nil(broker.BroadcastSync(context.Background(), 42))
+	assertions.Equal(uint64(2), broker.Stats().Delivered)
+	assertions.Equal(42, <-received)
+	assertions.Equal(42, <-received)
+
+	broker.Close()
+	assertions.ErrorIs(broker.BroadcastSync(context.Background(), 42), ErrClosed)
+}
+
+func TestBroadcastSyncContextDone(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+	_, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// the client never receives the message
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = broker.BroadcastSync(ctx, 42)
+	assertions.ErrorIs(err, context.DeadlineExceeded)
+	assertions.EqualError(err, "broker: context deadline exceeded")
+	assertions.Eventually(func() bool {
+		return broker.Stats().Dropped == 1
+	}, time.Second, 10*time.Millisecond)
+
+	mirror, err := NewMirror(broker)
+	assertions.Nil(err)
+	assertions.ErrorIs(mirror.BroadcastSync(context.Background(), 42), ErrReadOnly)
+
+	broker.Close()
+}
+
+func TestBroadcastSyncDropped(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+	go func() {
+		for range client {
+		}
+	}()
+	// the broker does not wait for the client dropping the message
+	_, err = broker.Subscribe(WithOverflow(DropNewest))
+	assertions.Nil(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assertions.ErrorIs(broker.BroadcastSync(ctx, 42), ErrNotDelivered)
+	assertions.Equal(uint64(1), broker.Stats().Delivered)
+	assertions.Equal(uint64(1), broker.Stats().Dropped)
+
+	broker.Close()
+}
+
+func TestBroadcastSyncClose(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := New[int]()
+	_, err := broker.Subscribe()
+	assertions.Nil(err)
+
+	// the client never receives the message, but closing the broker stops waiting for it
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		broker.Close()
+	}()
+	assertions.ErrorIs(broker.BroadcastSync(context.Background(), 42), ErrClosed)
+}
+
+func TestPublishSync(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[int]().Timeout(100 * time.Millisecond).Build()
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+	_, err = broker.Subscribe()
+	assertions.Nil(err)
+	received := make(chan int, 1)
+	go func() {
+		received <- <-client
+	}()
+
+	// the message was sent to the receiving client, and the other client was given up on when it returns
+	start := time.Now()
+	assertions.Nil(broker.PublishSync(42))
+	assertions.GreaterOrEqual(time.Since(start), 100*time.Millisecond)
+	assertions.Equal(uint64(1), broker.Stats().Delivered)
+	assertions.Equal(uint64(1), broker.Stats().Dropped)
+	assertions.Equal(42, <-received)
+
+	broker.Close()
+	assertions.ErrorIs(broker.PublishSync(42), ErrClosed)
+}
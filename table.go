@@ -0,0 +1,182 @@
+package broker
+
+import "sync"
+
+// Table is a materialized view of a broker that maintains the latest message per key.
+type Table[K comparable, V any] struct {
+	mutex   sync.RWMutex
+	values  map[K]V
+	version uint64
+	watches map[Client[V]]*watch[K, V]
+	key     func(V) K
+	source  *Broker[V]
+	client  Client[V]
+	changes *Broker[Change[K, V]]
+	done    chan void
+}
+
+// watch holds the state of a client watching a key of a table.
+type watch[K comparable, V any] struct {
+	changes Client[Change[K, V]]
+	stop    chan void
+}
+
+// Change describes an update of a table entry, which is sent to the clients subscribed to the table.
+type Change[K comparable, V any] struct {
+	// Key is the key of the updated entry.
+	Key K
+	// Old is the previous value of the entry, if Existed is true.
+	Old V
+	// New is the current value of the entry.
+	New V
+	// Existed reports whether the entry existed before the update.
+	Existed bool
+	// Version is the version of the table after the update, which increases with every update.
+	Version uint64
+}
+
+// NewTable subscribes to the source broker and constructs a new table,
+// which maintains the latest message per key as determined by the key function.
+// Returns ErrTimeout on timeout.
+func NewTable[K comparable, V any](source *Broker[V], key func(V) K) (*Table[K, V], error) {
+	client, err := source.Subscribe()
+	if err != nil {
+		return nil, err
+	}
+	table := &Table[K, V]{
+		values:  make(map[K]V),
+		watches: make(map[Client[V]]*watch[K, V]),
+		key:     key,
+		source:  source,
+		client:  client,
+		changes: NewBuilder[Change[K, V]]().Timeout(source.currentTimeout()).Build(),
+		done:    make(chan void),
+	}
+	go table.run()
+	return table, nil
+}
+
+// Get returns the latest value for a key, and whether there is a value for the key.
+func (table *Table[K, V]) Get(key K) (V, bool) {
+	table.mutex.RLock()
+	defer table.mutex.RUnlock()
+	value, ok := table.values[key]
+	return value, ok
+}
+
+// All returns a copy of the latest values of all keys.
+func (table *Table[K, V]) All() map[K]V {
+	table.mutex.RLock()
+	defer table.mutex.RUnlock()
+	values := make(map[K]V, len(table.values))
+	for key, value := range table.values {
+		values[key] = value
+	}
+	return values
+}
+
+// Subscribe registers a new client to the table, which receives all subsequent changes.
+// Returns ErrTimeout on timeout.
+func (table *Table[K, V]) Subscribe() (Client[Change[K, V]], error) {
+	return table.changes.Subscribe()
+}
+
+// Unsubscribe removes a client from the table.
+// Returns ErrTimeout on timeout.
+func (table *Table[K, V]) Unsubscribe(client Client[Change[K, V]]) error {
+	return table.changes.Unsubscribe(client)
+}
+
+// Watch registers a new client to the table, which immediately receives the latest value for the key (if any),
+// and then all subsequent values for the key. The values are coalesced for a slow client: a value replaced before
+// the client received it is skipped, so that the client always receives the latest value without delaying the table.
+// Returns ErrTimeout on timeout.
+func (table *Table[K, V]) Watch(key K) (Client[V], error) {
+	changes, err := table.changes.Subscribe()
+	if err != nil {
+		return nil, err
+	}
+	client := make(Client[V])
+	w := &watch[K, V]{changes: changes, stop: make(chan void)}
+
+	table.mutex.Lock()
+	table.watches[client] = w
+	value, ok := table.values[key]
+	version := table.version
+	table.mutex.Unlock()
+
+	go func() {
+		defer close(client)
+		// keep receiving the changes while the client does not receive the latest value,
+		// so that the changes are not dropped for the watch
+		var pending Client[V]
+		if ok {
+			pending = client
+		}
+		for {
+			select {
+			case change, open := <-w.changes:
+				if !open {
+					return
+				}
+				// skip changes of other keys, and changes already contained in the latest value
+				if change.Key != key || change.Version <= version {
+					continue
+				}
+				value, pending = change.New, client
+			case pending <- value:
+				pending = nil
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+	return client, nil
+}
+
+// Unwatch removes a client watching a key from the table.
+// Has no effect if the client is not watching a key of the table.
+// Returns ErrTimeout on timeout.
+func (table *Table[K, V]) Unwatch(client Client[V]) error {
+	table.mutex.Lock()
+	w, ok := table.watches[client]
+	delete(table.watches, client)
+	table.mutex.Unlock()
+	if !ok {
+		return nil
+	}
+	close(w.stop)
+	return table.changes.Unsubscribe(w.changes)
+}
+
+// Close unsubscribes the table from the source broker, and closes all clients subscribed to the table.
+// Returns ErrTimeout on timeout.
+func (table *Table[K, V]) Close() error {
+	select {
+	case <-table.done:
+		return nil
+	default:
+	}
+	if err := table.source.Unsubscribe(table.client); err != nil {
+		return err
+	}
+	<-table.done
+	return nil
+}
+
+// run starts the table loop, which applies the messages of the source broker until it closes the client.
+func (table *Table[K, V]) run() {
+	defer close(table.done)
+	defer table.changes.Close()
+	for msg := range table.client {
+		key := table.key(msg)
+		table.mutex.Lock()
+		old, existed := table.values[key]
+		table.values[key] = msg
+		table.version++
+		change := Change[K, V]{Key: key, Old: old, New: msg, Existed: existed, Version: table.version}
+		table.mutex.Unlock()
+		// the change is discarded on timeout
+		_ = table.changes.Publish(change)
+	}
+}
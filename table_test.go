@@ -0,0 +1,149 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type reading struct {
+	sensor string
+	value  int
+}
+
+func readingSensor(r reading) string {
+	return r.sensor
+}
+
+func TestTable(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[reading]().Timeout(100 * time.Millisecond).Build()
+	table, err := NewTable(broker, readingSensor)
+	assertions.Nil(err)
+	assertions.NotNil(table)
+
+	changes, err := table.Subscribe()
+	assertions.Nil(err)
+
+	assertions.Nil(broker.Publish(reading{"a", 1}))
+	assertions.Equal(Change[string, reading]{Key: "a", New: reading{"a", 1}, Version: 1}, <-changes)
+
+	assertions.Nil(broker.Publish(reading{"b", 2}))
+	assertions.Equal(Change[string, reading]{Key: "b", New: reading{"b", 2}, Version: 2}, <-changes)
+
+	assertions.Nil(broker.Publish(reading{"a", 3}))
+	assertions.Equal(Change[string, reading]{Key: "a", Old: reading{"a", 1}, New: reading{"a", 3}, Existed: true, Version: 3}, <-changes)
+
+	value, ok := table.Get("a")
+	assertions.True(ok)
+	assertions.Equal(reading{"a", 3}, value)
+	_, ok = table.Get("c")
+	assertions.False(ok)
+	assertions.Equal(map[string]reading{"a": {"a", 3}, "b": {"b", 2}}, table.All())
+
+	assertions.Nil(table.Unsubscribe(changes))
+	_, ok = <-changes
+	assertions.False(ok)
+
+	changes, err = table.Subscribe()
+	assertions.Nil(err)
+
+	assertions.Nil(table.Close())
+	assertions.Nil(table.Close())
+	_, ok = <-changes
+	assertions.False(ok)
+
+	broker.Close()
+}
+
+func TestTableWatch(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[reading]().Timeout(100 * time.Millisecond).Build()
+	table, err := NewTable(broker, readingSensor)
+	assertions.Nil(err)
+
+	changes, err := table.Subscribe()
+	assertions.Nil(err)
+	assertions.Nil(broker.Publish(reading{"a", 1}))
+	<-changes
+	assertions.Nil(table.Unsubscribe(changes))
+
+	a, err := table.Watch("a")
+	assertions.Nil(err)
+	b, err := table.Watch("b")
+	assertions.Nil(err)
+	assertions.Equal(reading{"a", 1}, <-a)
+
+	assertions.Nil(broker.Publish(reading{"b", 2}))
+	assertions.Nil(broker.Publish(reading{"a", 3}))
+	assertions.Equal(reading{"b", 2}, <-b)
+	assertions.Equal(reading{"a", 3}, <-a)
+
+	assertions.Nil(table.Unwatch(a))
+	assertions.Nil(table.Unwatch(a))
+	_, ok := <-a
+	assertions.False(ok)
+
+	assertions.Nil(table.Close())
+	_, ok = <-b
+	assertions.False(ok)
+
+	broker.Close()
+}
+
+func TestTableWatchSlowClient(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[reading]().Timeout(100 * time.Millisecond).Build()
+	table, err := NewTable(broker, readingSensor)
+	assertions.Nil(err)
+
+	// the values replaced before the client received them are coalesced, without dropping changes
+	a, err := table.Watch("a")
+	assertions.Nil(err)
+	for value := 1; value <= 3; value++ {
+		assertions.Nil(broker.Publish(reading{"a", value}))
+	}
+	assertions.Eventually(func() bool {
+		return table.changes.Stats().Broadcasts == 3
+	}, time.Second, 10*time.Millisecond)
+	assertions.Equal(reading{"a", 3}, <-a)
+	assertions.Zero(table.changes.Stats().Dropped)
+
+	assertions.Nil(broker.Publish(reading{"a", 4}))
+	assertions.Equal(reading{"a", 4}, <-a)
+
+	assertions.Nil(table.Close())
+	broker.Close()
+}
+
+func TestTableSourceClosed(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[reading]().Timeout(100 * time.Millisecond).Build()
+	table, err := NewTable(broker, readingSensor)
+	assertions.Nil(err)
+
+	changes, err := table.Subscribe()
+	assertions.Nil(err)
+
+	broker.Close()
+	_, ok := <-changes
+	assertions.False(ok)
+	assertions.Nil(table.Close())
+}
+
+func TestNewTableClosed(t *testing.T) {
+	assertions := assert.New(t)
+
+	broker := NewBuilder[reading]().Timeout(100 * time.Millisecond).Build()
+	broker.Close()
+	time.Sleep(200 * time.Millisecond)
+
+	table, err := NewTable(broker, readingSensor)
+	assertions.Nil(table)
+	assertions.ErrorIs(err, ErrClosed)
+}
@@ -0,0 +1,172 @@
+package broker
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Topic is a typed key of a named broker hosted by a mux.
+// Topics are meant to be declared once and shared by publishers and subscribers,
+// so that they agree on the name and the message type of a topic at compile time.
+type Topic[T any] struct {
+	name string
+}
+
+// topicKey identifies the broker of a topic in a mux.
+// Topics with the same name but different message types are hosted by different brokers.
+type topicKey struct {
+	name string
+	typ  reflect.Type
+}
+
+// NewTopic constructs a new topic with a name.
+func NewTopic[T any](name string) Topic[T] {
+	return Topic[T]{name: name}
+}
+
+// Name returns the name of the topic.
+func (topic Topic[T]) Name() string {
+	return topic.name
+}
+
+// Broker returns the broker hosted by the mux for the topic.
+// A broker with default configuration, named after the topic, is created if none is hosted yet.
+func (topic Topic[T]) Broker(mux *Mux) *Broker[T] {
+	mux.mutex.Lock()
+	defer mux.mutex.Unlock()
+	key := topicKey{topic.name, typeOf[T]()}
+	if broker, ok := mux.topics[key]; ok {
+		return broker.(*Broker[T])
+	}
+	broker := NewBuilder[T]().Name(topic.name).Build()
+	broker.topic.Store(&topicHost{mux, key})
+	mux.topics[key] = broker
+	return broker
+}
+
+// Register hosts a custom configured broker for the topic in the mux, like a broker retaining the latest message.
+// Returns ErrAlreadyRegistered if the mux already hosts a broker for the topic, or the broker is hosted already,
+// by this or another mux.
+func (topic Topic[T]) Register(mux *Mux, broker *Broker[T]) error {
+	mux.mutex.Lock()
+	defer mux.mutex.Unlock()
+	key := topicKey{topic.name, typeOf[T]()}
+	if _, ok := mux.topics[key]; ok || mux.hosts(broker) || !broker.topic.CompareAndSwap(nil, &topicHost{mux, key}) {
+		return ErrAlreadyRegistered
+	}
+	mux.topics[key] = broker
+	return nil
+}
+
+// Publish publishes a message to the broker hosted by the mux for the topic.
+// The broker of a topic republishes every message it broadcasts to the brokers hosted for all patterns
+// matching the topic, so this is the same as publishing to the broker of the topic directly.
+// Returns ErrTimeout on timeout.
+func (topic Topic[T]) Publish(mux *Mux, message T) error {
+	return topic.Broker(mux).Publish(message)
+}
+
+// Subscribe registers a new client to the broker hosted by the mux for the topic and returns it to the caller.
+// Returns ErrTimeout on timeout.
+func (topic Topic[T]) Subscribe(mux *Mux) (Client[T], error) {
+	return topic.Broker(mux).Subscribe()
+}
+
+// Pattern is a typed wildcard pattern matching a family of topics by their names.
+// Topic names are split into tokens by dots. In a pattern, the token "*" matches exactly one token,
+// and the token ">" as last token matches one or more tokens, e.g. "orders.*" matches "orders.created",
+// and "sensors.>" matches "sensors.kitchen.temperature".
+type Pattern[T any] struct {
+	pattern string
+}
+
+// NewPattern constructs a new pattern.
+func NewPattern[T any](pattern string) Pattern[T] {
+	return Pattern[T]{pattern: pattern}
+}
+
+// Pattern returns the pattern string.
+func (pattern Pattern[T]) Pattern() string {
+	return pattern.pattern
+}
+
+// Matches reports whether the pattern matches the name of a topic.
+func (pattern Pattern[T]) Matches(name string) bool {
+	return matchPattern(pattern.pattern, name)
+}
+
+// Broker returns the broker hosted by the mux for the pattern, which receives the messages of all matching topics.
+// A broker with default configuration, named after the pattern, is created if none is hosted yet.
+func (pattern Pattern[T]) Broker(mux *Mux) *Broker[T] {
+	mux.mutex.Lock()
+	defer mux.mutex.Unlock()
+	key := topicKey{pattern.pattern, typeOf[T]()}
+	if broker, ok := mux.patterns[key]; ok {
+		return broker.(*Broker[T])
+	}
+	broker := NewBuilder[T]().Name(pattern.pattern).Build()
+	mux.patterns[key] = broker
+	// the matching patterns of the topics need to be determined again
+	for key := range mux.matches {
+		delete(mux.matches, key)
+	}
+	return broker
+}
+
+// Subscribe registers a new client to the broker hosted by the mux for the pattern and returns it to the caller.
+// The client receives the messages published to all topics matching the pattern with the same message type.
+// The messages of each topic keep their order, but the messages of different topics are interleaved
+// in the order the brokers of the topics broadcast them.
+// Returns ErrTimeout on timeout.
+func (pattern Pattern[T]) Subscribe(mux *Mux) (Client[T], error) {
+	return pattern.Broker(mux).Subscribe()
+}
+
+// topicHost is the mux hosting a broker for a topic.
+type topicHost struct {
+	mux *Mux
+	key topicKey
+}
+
+// publishToPatterns republishes a broadcast message to the brokers of all patterns matching the topic of the broker,
+// if the broker is hosted for a topic. Like a mirror, a pattern broker discards the message on timeout.
+func (broker *Broker[T]) publishToPatterns(env *envelope[T]) {
+	host := broker.topic.Load()
+	if host == nil {
+		return
+	}
+	for _, pattern := range host.mux.matching(host.key) {
+		_ = pattern.publishAny(env.message)
+	}
+}
+
+// matching returns the brokers of all patterns matching a topic.
+// The result is cached until a new pattern is added to the mux, so that patterns are not matched on every publish.
+func (mux *Mux) matching(key topicKey) []muxBroker {
+	mux.mutex.Lock()
+	defer mux.mutex.Unlock()
+	brokers, ok := mux.matches[key]
+	if !ok {
+		for patternKey, broker := range mux.patterns {
+			if patternKey.typ == key.typ && matchPattern(patternKey.name, key.name) {
+				brokers = append(brokers, broker)
+			}
+		}
+		mux.matches[key] = brokers
+	}
+	return brokers
+}
+
+// matchPattern reports whether a wildcard pattern matches the name of a topic.
+func matchPattern(pattern, name string) bool {
+	patternTokens, nameTokens := strings.Split(pattern, "."), strings.Split(name, ".")
+	for i, token := range patternTokens {
+		if token == ">" && i == len(patternTokens)-1 {
+			return len(nameTokens) > i
+		}
+		if i >= len(nameTokens) || (token != "*" && token != nameTokens[i]) {
+			return false
+		}
+	}
+	return len(patternTokens) == len(nameTokens)
+}
@@ -0,0 +1,126 @@
+package broker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopic(t *testing.T) {
+	assertions := assert.New(t)
+
+	orders, names := NewTopic[int]("orders"), NewTopic[string]("orders")
+	assertions.Equal("orders", orders.Name())
+
+	mux := NewMux()
+	broker := orders.Broker(mux)
+	assertions.Same(broker, orders.Broker(mux))
+	assertions.Same(broker, NewTopic[int]("orders").Broker(mux))
+	assertions.Equal(`broker "orders"`, broker.String())
+
+	// topics with the same name but different message types are hosted by different brokers
+	client, err := orders.Subscribe(mux)
+	assertions.Nil(err)
+	nameClient, err := names.Subscribe(mux)
+	assertions.Nil(err)
+	assertions.Len(mux.topics, 2)
+	assertions.Empty(mux.brokers)
+
+	assertions.Nil(orders.Publish(mux, 42))
+	assertions.Nil(names.Publish(mux, "Hello"))
+	assertions.Equal(42, <-client)
+	assertions.Equal("Hello", <-nameClient)
+
+	mux.Close()
+	assertions.Empty(mux.topics)
+	_, ok := <-client
+	assertions.False(ok)
+}
+
+func TestPattern(t *testing.T) {
+	assertions := assert.New(t)
+
+	orders := NewPattern[int]("orders.*")
+	assertions.Equal("orders.*", orders.Pattern())
+
+	mux := NewMux()
+	broker := orders.Broker(mux)
+	assertions.Same(broker, orders.Broker(mux))
+	assertions.Equal(`broker "orders.*"`, broker.String())
+
+	client, err := orders.Subscribe(mux)
+	assertions.Nil(err)
+	sensors, err := NewPattern[int]("sensors.>").Subscribe(mux)
+	assertions.Nil(err)
+	topic, err := NewTopic[int]("orders.created").Subscribe(mux)
+	assertions.Nil(err)
+
+	assertions.Nil(NewTopic[int]("orders.created").Publish(mux, 1))
+	assertions.Nil(NewTopic[int]("orders.created.eu").Publish(mux, 2))
+	assertions.Nil(NewTopic[int]("sensors.kitchen.temperature").Publish(mux, 3))
+	assertions.Nil(NewTopic[string]("orders.deleted").Publish(mux, "Hello"))
+	assertions.Nil(NewTopic[int]("orders.deleted").Publish(mux, 4))
+
+	// the messages of different topics are interleaved in the order their brokers broadcast them
+	assertions.Equal(1, <-topic)
+	assertions.ElementsMatch([]int{1, 4}, []int{<-client, <-client})
+	assertions.Equal(3, <-sensors)
+
+	// the broker of a topic republishes to the matching patterns, even if published to directly
+	assertions.Nil(NewTopic[int]("orders.created").Broker(mux).Publish(5))
+	assertions.Equal(5, <-topic)
+	assertions.Equal(5, <-client)
+	registered := NewBuilder[int]().Name("sensors.hallway").Build()
+	assertions.Nil(NewTopic[int]("sensors.hallway").Register(mux, registered))
+	assertions.Nil(registered.Publish(6))
+	assertions.Equal(6, <-sensors)
+
+	mux.Close()
+	assertions.Empty(mux.patterns)
+	_, ok := <-client
+	assertions.False(ok)
+}
+
+func TestPatternMatches(t *testing.T) {
+	assertions := assert.New(t)
+
+	for _, test := range []struct {
+		pattern string
+		name    string
+		matches bool
+	}{
+		{"orders", "orders", true},
+		{"orders", "orders.created", false},
+		{"orders.*", "orders.created", true},
+		{"orders.*", "orders", false},
+		{"orders.*", "orders.created.eu", false},
+		{"*.created", "orders.created", true},
+		{"orders.>", "orders.created.eu", true},
+		{"orders.>", "orders", false},
+		{">", "orders", true},
+		{"orders.>.eu", "orders.>.eu", true},
+		{"orders.>.eu", "orders.created.eu", false},
+	} {
+		assertions.Equal(test.matches, NewPattern[int](test.pattern).Matches(test.name), "%q %q", test.pattern, test.name)
+	}
+}
+
+func TestTopicRegister(t *testing.T) {
+	assertions := assert.New(t)
+
+	temperature := NewTopic[string]("temperature")
+	mux := NewMux()
+	broker := NewBuilder[string]().Name("temperature").Retain().Build()
+	assertions.Nil(temperature.Register(mux, broker))
+	assertions.ErrorIs(temperature.Register(mux, broker), ErrAlreadyRegistered)
+	assertions.ErrorIs(temperature.Register(NewMux(), broker), ErrAlreadyRegistered)
+	assertions.Same(broker, temperature.Broker(mux))
+
+	// a broker is hosted only once, so that the mux closes it only once
+	assertions.ErrorIs(Register(mux, broker), ErrAlreadyRegistered)
+	typed := New[int]()
+	assertions.Nil(Register(mux, typed))
+	assertions.ErrorIs(NewTopic[int]("counter").Register(mux, typed), ErrAlreadyRegistered)
+
+	mux.Close()
+}
@@ -0,0 +1,46 @@
+package broker
+
+import "time"
+
+// Trace is the delivery record of a single message, which is passed to the trace hook of a broker.
+type Trace[T any] struct {
+	// Message is the traced message.
+	Message T
+	// Key is the key of the message.
+	Key string
+	// Headers are the headers of the message.
+	Headers map[string]string
+	// Published is the time the message was accepted by the broker.
+	Published time.Time
+	// Dispatched is the time the broker took the message from its buffer to broadcast it.
+	Dispatched time.Time
+	// Expired reports whether the message was discarded because its time to live elapsed.
+	Expired bool
+	// Suppressed reports whether the message was discarded because it equals the previously broadcast message,
+	// or duplicates a message published within the dedup window.
+	Suppressed bool
+	// Deliveries are the sends of the message to the clients, in the order they were attempted.
+	// Clients skipped by their filter are not included.
+	Deliveries []Delivery[T]
+}
+
+// Delivery records the send of a message to a single client.
+type Delivery[T any] struct {
+	// Client is the client the message was sent to.
+	Client Client[T]
+	// At is the time the client received the message, or the time the message was dropped.
+	At time.Time
+	// Dropped reports whether the message was discarded because the client did not receive it in time.
+	Dropped bool
+}
+
+// trace starts the delivery record of the message.
+func (env *envelope[T]) trace() *Trace[T] {
+	return &Trace[T]{
+		Message:    env.message,
+		Key:        env.key,
+		Headers:    env.headers,
+		Published:  env.published,
+		Dispatched: time.Now(),
+	}
+}
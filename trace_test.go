@@ -0,0 +1,58 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+	assertions := assert.New(t)
+
+	traces := make(chan Trace[int], 10)
+	broker := NewDedupBuilder[int]().Timeout(100 * time.Millisecond).Trace(func(trace Trace[int]) {
+		traces <- trace
+	}).Build()
+
+	client, err := broker.Subscribe()
+	assertions.Nil(err)
+	idle, err := broker.Subscribe()
+	assertions.Nil(err)
+	assertions.Nil(broker.SetFilter(idle, func(msg int) bool { return msg > 1 }))
+
+	before := time.Now()
+	assertions.Nil(broker.PublishWithOptions(1, WithKey("key"), WithHeader("name", "value")))
+	assertions.Equal(1, <-client)
+	trace := <-traces
+	assertions.Equal(1, trace.Message)
+	assertions.Equal("key", trace.Key)
+	assertions.Equal(map[string]string{"name": "value"}, trace.Headers)
+	assertions.False(trace.Published.Before(before))
+	assertions.False(trace.Dispatched.Before(trace.Published))
+	assertions.Len(trace.Deliveries, 1)
+	assertions.Equal(client, trace.Deliveries[0].Client)
+	assertions.False(trace.Deliveries[0].At.Before(trace.Dispatched))
+	assertions.False(trace.Deliveries[0].Dropped)
+
+	// the idle client does not receive the message, so that it is dropped
+	assertions.Nil(broker.Publish(2))
+	assertions.Equal(2, <-client)
+	trace = <-traces
+	assertions.Len(trace.Deliveries, 2)
+	for _, delivery := range trace.Deliveries {
+		assertions.Equal(delivery.Client == idle, delivery.Dropped)
+	}
+
+	assertions.Nil(broker.Publish(2))
+	trace = <-traces
+	assertions.True(trace.Suppressed)
+	assertions.Empty(trace.Deliveries)
+
+	assertions.Nil(broker.PublishWithOptions(3, WithTTL(time.Nanosecond)))
+	trace = <-traces
+	assertions.True(trace.Expired)
+	assertions.Empty(trace.Deliveries)
+
+	broker.Close()
+}
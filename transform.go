@@ -0,0 +1,38 @@
+package broker
+
+// Map constructs a new read-only broker, which publishes every message of the source broker converted by the function,
+// using the timeout of the source and the default buffer size. Like a mirror, the derived broker is closed when the
+// source is closed, and closing the derived broker unsubscribes it from the source.
+// Closing the derived broker after the source closed it has no effect.
+// Returns ErrTimeout on timeout, or ErrClosed if the source is closed.
+func Map[T, U any](source *Broker[T], convert func(T) U) (*Broker[U], error) {
+	return FlatMap(source, func(msg T) []U { return []U{convert(msg)} })
+}
+
+// FlatMap constructs a new read-only broker like Map, which publishes all messages the function converts every
+// message of the source broker into, in order. The message is dropped if the function returns no messages.
+// Returns ErrTimeout on timeout, or ErrClosed if the source is closed.
+func FlatMap[T, U any](source *Broker[T], convert func(T) []U) (*Broker[U], error) {
+	return derive(source, convert)
+}
+
+// Filter constructs a new read-only broker like Map, which only publishes the messages of the source broker
+// matching the predicate. This hands out a narrowed view of the source, without access to publish to it.
+// The predicate is evaluated by the source broker like the filter of a client.
+// Returns ErrTimeout on timeout, or ErrClosed if the source is closed.
+func Filter[T any](source *Broker[T], predicate func(T) bool) (*Broker[T], error) {
+	return derive(source, func(msg T) []T { return []T{msg} }, WithFilter(predicate))
+}
+
+// derive subscribes to the source broker with the options, and constructs a new read-only broker
+// publishing the converted messages of the source.
+func derive[T, U any](source *Broker[T], convert func(T) []U, opts ...SubscribeOption) (*Broker[U], error) {
+	client, err := source.Subscribe(opts...)
+	if err != nil {
+		return nil, err
+	}
+	derived := NewBuilder[U]().Timeout(source.currentTimeout()).Build()
+	derived.readOnly = true
+	go forward(derived, source, client, convert)
+	return derived, nil
+}
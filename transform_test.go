@@ -0,0 +1,99 @@
+package broker
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMap(t *testing.T) {
+	assertions := assert.New(t)
+
+	source := New[int]()
+	derived, err := Map(source, strconv.Itoa)
+	assertions.Nil(err)
+	client, err := derived.Subscribe()
+	assertions.Nil(err)
+
+	assertions.Nil(source.Publish(42))
+	assertions.Equal("42", <-client)
+	assertions.ErrorIs(derived.Publish("1"), ErrReadOnly)
+
+	// the derived broker is closed with the source
+	source.Close()
+	_, ok := <-client
+	assertions.False(ok)
+	assertions.ErrorIs(derived.Run(context.Background()), ErrClosed)
+}
+
+func TestFlatMap(t *testing.T) {
+	assertions := assert.New(t)
+
+	source := New[string]()
+	words, err := FlatMap(source, strings.Fields)
+	assertions.Nil(err)
+	client, err := words.Subscribe(WithBufferSize(10))
+	assertions.Nil(err)
+
+	assertions.Nil(source.Publish("hello broker"))
+	assertions.Nil(source.Publish(""))
+	assertions.Nil(source.Publish("bye"))
+	assertions.Equal([]string{"hello", "broker", "bye"}, []string{<-client, <-client, <-client})
+
+	// closing the derived broker unsubscribes it from the source
+	words.Close()
+	assertions.Eventually(func() bool {
+		return source.Stats().Subscribers == 0
+	}, time.Second, 10*time.Millisecond)
+
+	source.Close()
+}
+
+func TestMapCloseAfterSource(t *testing.T) {
+	assertions := assert.New(t)
+
+	source := New[int]()
+	derived, err := Map(source, strconv.Itoa)
+	assertions.Nil(err)
+
+	source.Close()
+	<-derived.Done()
+	assertions.NotPanics(derived.Close)
+}
+
+func TestMapClosed(t *testing.T) {
+	assertions := assert.New(t)
+
+	source := New[int]()
+	source.Close()
+	derived, err := Map(source, strconv.Itoa)
+	assertions.Nil(derived)
+	assertions.ErrorIs(err, ErrClosed)
+}
+
+func TestFilter(t *testing.T) {
+	assertions := assert.New(t)
+
+	source := New[int]()
+	even, err := Filter(source, func(msg int) bool { return msg%2 == 0 })
+	assertions.Nil(err)
+	client, err := even.Subscribe(WithBufferSize(10))
+	assertions.Nil(err)
+
+	for msg := 1; msg <= 4; msg++ {
+		assertions.Nil(source.Publish(msg))
+	}
+	assertions.Equal([]int{2, 4}, []int{<-client, <-client})
+	assertions.ErrorIs(even.Publish(6), ErrReadOnly)
+
+	source.Close()
+	_, ok := <-client
+	assertions.False(ok)
+	// the filtered broker is closed by the source, and can still be closed by its owner
+	<-even.Done()
+	assertions.NotPanics(even.Close)
+}
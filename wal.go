@@ -0,0 +1,293 @@
+package broker
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ErrWALClosed is the error returned when a message is written to a closed write-ahead log.
+var ErrWALClosed = errors.New("write-ahead log closed")
+
+// WAL is an append-only write-ahead log on disk, which makes the messages published to a broker durable:
+// every accepted message is written and synced to disk before the publisher returns,
+// and acknowledged after the broker broadcast it (or discarded it as expired or suppressed).
+// Messages that were not acknowledged when the process stopped are replayed by the broker on startup,
+// so that every message is broadcast at least once. Messages are encoded as JSON lines.
+// The log is truncated whenever all messages are acknowledged, and compacted to the unacknowledged messages
+// when acknowledged records make up most of it, so that it does not grow under constant load.
+type WAL[T any] struct {
+	mutex     sync.Mutex
+	file      *os.File
+	path      string
+	next      uint64
+	unacked   map[uint64]T
+	records   int
+	recovered []walEntry[T]
+}
+
+// walCompactionRecords specifies the number of records in the log from which on it is compacted,
+// once less than half of them are unacknowledged messages.
+const walCompactionRecords = 1024
+
+// walEntry is an unacknowledged message recovered from the log.
+type walEntry[T any] struct {
+	offset  uint64
+	message T
+}
+
+// walRecord is a line of the log, which either appends a message or acknowledges the message at the offset.
+type walRecord[T any] struct {
+	Offset  uint64 `json:"offset"`
+	Message *T     `json:"message,omitempty"`
+	Ack     bool   `json:"ack,omitempty"`
+}
+
+// OpenWAL opens the write-ahead log at the path, creating it if it does not exist,
+// and recovers the messages that were not acknowledged, which are replayed by the broker configured with it.
+// A record torn by a crash while it was written is discarded.
+// Returns the error encountered when reading or rewriting the log.
+func OpenWAL[T any](path string) (*WAL[T], error) {
+	wal := &WAL[T]{path: path, unacked: make(map[uint64]T)}
+	if err := wal.recover(); err != nil {
+		return nil, err
+	}
+	// rewrite the log with the recovered messages only, so that it does not grow across restarts
+	if err := wal.rewrite(wal.recovered); err != nil {
+		return nil, err
+	}
+	for _, entry := range wal.recovered {
+		wal.unacked[entry.offset] = entry.message
+	}
+	return wal, nil
+}
+
+// rewrite replaces the log by a log of the entries only, which is synced to disk before it replaces the log.
+// The log is kept unchanged on error.
+func (wal *WAL[T]) rewrite(entries []walEntry[T]) error {
+	tmp := wal.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	previous := wal.file
+	wal.file = file
+	err = func() error {
+		for i := range entries {
+			entry := &entries[i]
+			if err := wal.write(walRecord[T]{Offset: entry.offset, Message: &entry.message}); err != nil {
+				return err
+			}
+		}
+		if err := file.Sync(); err != nil {
+			return err
+		}
+		return os.Rename(tmp, wal.path)
+	}()
+	if err != nil {
+		_ = file.Close()
+		wal.file = previous
+		return err
+	}
+	if previous != nil {
+		_ = previous.Close()
+	}
+	wal.records = len(entries)
+	return nil
+}
+
+// Close closes the log. Messages appended afterwards are rejected with ErrWALClosed.
+// Returns ErrWALClosed if the log is already closed.
+func (wal *WAL[T]) Close() error {
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+	if wal.file == nil {
+		return ErrWALClosed
+	}
+	err := wal.file.Close()
+	wal.file = nil
+	return err
+}
+
+// recover reads the log and collects the messages that were not acknowledged, in the order they were appended.
+func (wal *WAL[T]) recover() error {
+	file, err := os.Open(wal.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var entries []walEntry[T]
+	acked := make(map[uint64]void)
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		var record walRecord[T]
+		if len(line) == 0 || json.Unmarshal(line, &record) != nil {
+			// the end of the log, or a torn record
+			break
+		}
+		if record.Offset >= wal.next {
+			wal.next = record.Offset + 1
+		}
+		if record.Ack {
+			acked[record.Offset] = void{}
+		} else if record.Message != nil {
+			entries = append(entries, walEntry[T]{record.Offset, *record.Message})
+		}
+		if err != nil {
+			break
+		}
+	}
+	for _, entry := range entries {
+		if _, ok := acked[entry.offset]; !ok {
+			wal.recovered = append(wal.recovered, entry)
+		}
+	}
+	return nil
+}
+
+// append writes a message to the log and syncs it to disk, and returns its offset.
+// Returns ErrWALClosed if the log is closed, or the error encountered when writing the message.
+func (wal *WAL[T]) append(message T) (uint64, error) {
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+	if wal.file == nil {
+		return 0, ErrWALClosed
+	}
+	offset := wal.next
+	if err := wal.write(walRecord[T]{Offset: offset, Message: &message}); err != nil {
+		return 0, err
+	}
+	if err := wal.file.Sync(); err != nil {
+		return 0, err
+	}
+	wal.next++
+	wal.unacked[offset] = message
+	wal.records++
+	return offset, nil
+}
+
+// ack acknowledges the message at the offset, which is not replayed anymore then.
+// The log is truncated when all messages are acknowledged, and compacted when most of its records are acknowledged.
+// The acknowledgement is not synced to disk, if it is lost the message is replayed again.
+func (wal *WAL[T]) ack(offset uint64) {
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+	if wal.file == nil {
+		return
+	}
+	delete(wal.unacked, offset)
+	if len(wal.unacked) == 0 {
+		if wal.file.Truncate(0) == nil {
+			if _, err := wal.file.Seek(0, io.SeekStart); err == nil {
+				wal.records = 0
+				return
+			}
+		}
+	}
+	if wal.write(walRecord[T]{Offset: offset, Ack: true}) == nil {
+		wal.records++
+	}
+	if wal.records >= walCompactionRecords && wal.records > 2*len(wal.unacked) {
+		// the log is kept if it cannot be compacted, and compacted again with the next acknowledgement
+		_ = wal.compact()
+	}
+}
+
+// compact rewrites the log with the unacknowledged messages only, in the order they were appended.
+func (wal *WAL[T]) compact() error {
+	entries := make([]walEntry[T], 0, len(wal.unacked))
+	for offset, message := range wal.unacked {
+		entries = append(entries, walEntry[T]{offset, message})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].offset < entries[j].offset
+	})
+	return wal.rewrite(entries)
+}
+
+// write writes a record as a single line to the log.
+func (wal *WAL[T]) write(record walRecord[T]) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = wal.file.Write(append(line, '\n'))
+	return err
+}
+
+// takeRecovered returns the recovered messages, which are only returned once.
+func (wal *WAL[T]) takeRecovered() []walEntry[T] {
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+	recovered := wal.recovered
+	wal.recovered = nil
+	return recovered
+}
+
+// WAL configures a write-ahead log, to which every published message is written and synced before it is accepted.
+// The messages recovered by the log are replayed before any new messages, as soon as the first client subscribed
+// to the broker (see ReplayAfterSubscribers), so that they are not broadcast before anyone can receive them.
+// Until then, the broker holds all messages, and publishers block when the buffer is full.
+// A message that cannot be written to the log is rejected with the error of the log.
+func (builder Builder[T]) WAL(wal *WAL[T]) Builder[T] {
+	builder.wal = wal
+	return builder
+}
+
+// ReplayAfterSubscribers configures the number of clients that must subscribe to the broker before the messages
+// recovered by the write-ahead log are replayed, so that all of them receive the recovered messages.
+// Defaults to one. Until then, publishers block when the buffer is full, and time out eventually.
+func (builder Builder[T]) ReplayAfterSubscribers(subscribers int) Builder[T] {
+	builder.replayAfter = subscribers
+	return builder
+}
+
+// log writes a published message to the write-ahead log of the broker, if any.
+func (broker *Broker[T]) log(env *envelope[T]) error {
+	if broker.wal == nil {
+		return nil
+	}
+	offset, err := broker.wal.append(env.message)
+	if err != nil {
+		return broker.error(err)
+	}
+	env.logged, env.offset = true, offset
+	return nil
+}
+
+// acknowledge acknowledges a message in the write-ahead log of the broker, if it was written to it.
+func (broker *Broker[T]) acknowledge(env *envelope[T]) {
+	if env.logged {
+		broker.wal.ack(env.offset)
+	}
+}
+
+// recoverMessages adds the messages recovered by the write-ahead log to the pending messages,
+// which are held until enough clients subscribed.
+func (broker *Broker[T]) recoverMessages() {
+	for _, entry := range broker.wal.takeRecovered() {
+		env := broker.wrap(entry.message, publishOptions{})
+		env.logged, env.offset = true, entry.offset
+		broker.track(&env)
+		broker.pending.push(env)
+		broker.holding = true
+	}
+}
+
+// release stops holding the messages once enough clients subscribed to replay the recovered messages.
+func (broker *Broker[T]) release() {
+	if broker.holding && len(broker.clients) >= broker.replayAfter {
+		broker.holding = false
+	}
+}
@@ -0,0 +1,180 @@
+package broker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWAL(t *testing.T) {
+	assertions := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "broker.wal")
+	wal, err := OpenWAL[string](path)
+	assertions.Nil(err)
+	first, err := wal.append("first")
+	assertions.Nil(err)
+	second, err := wal.append("second")
+	assertions.Nil(err)
+	assertions.Equal(first+1, second)
+	wal.ack(first)
+	assertions.Nil(wal.Close())
+	assertions.ErrorIs(wal.Close(), ErrWALClosed)
+	_, err = wal.append("third")
+	assertions.ErrorIs(err, ErrWALClosed)
+
+	// simulate a crash while writing a record
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	assertions.Nil(err)
+	_, err = file.WriteString(`{"offset":2,"mess`)
+	assertions.Nil(err)
+	assertions.Nil(file.Close())
+
+	wal, err = OpenWAL[string](path)
+	assertions.Nil(err)
+	theBroker := NewBuilder[string]().Timeout(100 * time.Millisecond).WAL(wal).Build()
+	assertions.Nil(theBroker.Publish("third"))
+	client, err := theBroker.Subscribe()
+	assertions.Nil(err)
+	assertions.Equal("second", <-client)
+	assertions.Equal("third", <-client)
+
+	// the log is truncated after all messages were acknowledged
+	time.Sleep(100 * time.Millisecond)
+	info, err := os.Stat(path)
+	assertions.Nil(err)
+	assertions.Zero(info.Size())
+
+	theBroker.Close()
+	assertions.Nil(wal.Close())
+}
+
+func TestWALCompaction(t *testing.T) {
+	assertions := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "broker.wal")
+	wal, err := OpenWAL[int](path)
+	assertions.Nil(err)
+
+	// the first message is never acknowledged, so that the log is not truncated
+	first, err := wal.append(0)
+	assertions.Nil(err)
+	for msg := 1; msg < 2*walCompactionRecords; msg++ {
+		offset, err := wal.append(msg)
+		assertions.Nil(err)
+		wal.ack(offset)
+	}
+	assertions.Less(wal.records, walCompactionRecords)
+	assertions.Nil(wal.Close())
+
+	wal, err = OpenWAL[int](path)
+	assertions.Nil(err)
+	assertions.Equal([]walEntry[int]{{first, 0}}, wal.takeRecovered())
+	assertions.Nil(wal.Close())
+}
+
+func TestWALReplayAfterSubscribers(t *testing.T) {
+	assertions := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "broker.wal")
+	wal, err := OpenWAL[int](path)
+	assertions.Nil(err)
+	_, err = wal.append(1)
+	assertions.Nil(err)
+	assertions.Nil(wal.Close())
+
+	wal, err = OpenWAL[int](path)
+	assertions.Nil(err)
+	theBroker := NewBuilder[int]().Timeout(100 * time.Millisecond).ReplayAfterSubscribers(2).WAL(wal).Build()
+	client1, err := theBroker.Subscribe(WithBufferSize(1))
+	assertions.Nil(err)
+	select {
+	case <-client1:
+		assertions.Fail("Received message not expected")
+	case <-time.After(100 * time.Millisecond):
+	}
+	client2, err := theBroker.Subscribe(WithBufferSize(1))
+	assertions.Nil(err)
+	assertions.Equal(1, <-client1)
+	assertions.Equal(1, <-client2)
+
+	theBroker.Close()
+	assertions.Nil(wal.Close())
+}
+
+func TestWALReplayIgnoresExpectedSubscribers(t *testing.T) {
+	assertions := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "broker.wal")
+	wal, err := OpenWAL[int](path)
+	assertions.Nil(err)
+	_, err = wal.append(1)
+	assertions.Nil(err)
+	assertions.Nil(wal.Close())
+
+	wal, err = OpenWAL[int](path)
+	assertions.Nil(err)
+	theBroker := NewBuilder[int]().Timeout(100 * time.Millisecond).ExpectedSubscribers(1000).WAL(wal).Build()
+	client, err := theBroker.Subscribe(WithBufferSize(2))
+	assertions.Nil(err)
+	assertions.Nil(theBroker.Publish(2))
+	assertions.Equal(1, <-client)
+	assertions.Equal(2, <-client)
+
+	theBroker.Close()
+	assertions.Nil(wal.Close())
+}
+
+func TestWALCloseWithContextWhileHolding(t *testing.T) {
+	assertions := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "broker.wal")
+	wal, err := OpenWAL[int](path)
+	assertions.Nil(err)
+	_, err = wal.append(1)
+	assertions.Nil(err)
+	assertions.Nil(wal.Close())
+
+	wal, err = OpenWAL[int](path)
+	assertions.Nil(err)
+	theBroker := NewBuilder[int]().Timeout(100 * time.Millisecond).WAL(wal).Build()
+	assertions.Nil(theBroker.Publish(2))
+	assertions.Nil(theBroker.CloseWithContext(context.Background()))
+	assertions.Nil(wal.Close())
+
+	// the held messages are replayed on the next startup
+	wal, err = OpenWAL[int](path)
+	assertions.Nil(err)
+	theBroker = NewBuilder[int]().Timeout(100 * time.Millisecond).WAL(wal).Build()
+	client, err := theBroker.Subscribe(WithBufferSize(2))
+	assertions.Nil(err)
+	assertions.Equal(1, <-client)
+	assertions.Equal(2, <-client)
+
+	theBroker.Close()
+	assertions.Nil(wal.Close())
+}
+
+func TestWALClosed(t *testing.T) {
+	assertions := assert.New(t)
+
+	wal, err := OpenWAL[int](filepath.Join(t.TempDir(), "broker.wal"))
+	assertions.Nil(err)
+	assertions.Nil(wal.Close())
+	theBroker := NewBuilder[int]().Name("wal").Timeout(100 * time.Millisecond).WAL(wal).Build()
+	assertions.ErrorIs(theBroker.Publish(1), ErrWALClosed)
+	assertions.ErrorIs(theBroker.TryPublish(1), ErrWALClosed)
+	theBroker.Close()
+}
+
+func TestOpenWALInvalidPath(t *testing.T) {
+	assertions := assert.New(t)
+
+	wal, err := OpenWAL[int](filepath.Join(t.TempDir(), "missing", "broker.wal"))
+	assertions.Nil(wal)
+	assertions.Error(err)
+}
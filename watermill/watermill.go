@@ -0,0 +1,171 @@
+// Package watermill provides an adapter implementing the Watermill publisher and subscriber interfaces,
+// which uses a go-broker per topic as in-process transport.
+package watermill
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/mpe85/go-broker"
+)
+
+// PubSub implements message.Publisher and message.Subscriber backed by one broker per topic.
+type PubSub struct {
+	mutex       sync.Mutex
+	builder     broker.Builder[*message.Message]
+	topics      map[string]*broker.Broker[*message.Message]
+	closing     chan struct{}
+	closed      bool
+	subscribers sync.WaitGroup
+}
+
+// ErrClosed is the error returned when the pub/sub is already closed.
+var ErrClosed = errors.New("pub/sub closed")
+
+// New constructs a new pub/sub that builds a broker for each topic with the configuration of the builder.
+func New(builder broker.Builder[*message.Message]) *PubSub {
+	return &PubSub{
+		builder: builder,
+		topics:  make(map[string]*broker.Broker[*message.Message]),
+		closing: make(chan struct{}),
+	}
+}
+
+// Publish publishes messages to the broker of the topic.
+// Returns ErrClosed if the pub/sub is closed, or broker.ErrTimeout on timeout.
+func (pubSub *PubSub) Publish(topic string, messages ...*message.Message) error {
+	topicBroker, err := pubSub.topic(topic)
+	if err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		if err := topicBroker.Publish(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Subscribe subscribes to the broker of the topic and returns a channel with its messages.
+// Each message must be acknowledged before the next one is sent, a negatively acknowledged message is sent again.
+// The channel is closed when the context is done or the pub/sub is closed.
+// Returns ErrClosed if the pub/sub is closed, or broker.ErrTimeout on timeout.
+func (pubSub *PubSub) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	topicBroker, err := pubSub.topic(topic)
+	if err != nil {
+		return nil, err
+	}
+	client, err := topicBroker.Subscribe()
+	if err != nil {
+		return nil, err
+	}
+
+	output := make(chan *message.Message)
+	pubSub.subscribers.Add(1)
+	go func() {
+		defer pubSub.subscribers.Done()
+		defer close(output)
+		for {
+			select {
+			case msg, ok := <-client:
+				if !ok {
+					return
+				}
+				if !pubSub.deliver(ctx, msg, output) {
+					pubSub.unsubscribe(topicBroker, client)
+					return
+				}
+			case <-ctx.Done():
+				pubSub.unsubscribe(topicBroker, client)
+				return
+			}
+		}
+	}()
+	return output, nil
+}
+
+// Close closes the brokers of all topics, and all channels returned by Subscribe.
+func (pubSub *PubSub) Close() error {
+	pubSub.mutex.Lock()
+	if pubSub.closed {
+		pubSub.mutex.Unlock()
+		return nil
+	}
+	pubSub.closed = true
+	close(pubSub.closing)
+	for _, topicBroker := range pubSub.topics {
+		topicBroker.Close()
+	}
+	pubSub.mutex.Unlock()
+
+	pubSub.subscribers.Wait()
+	return nil
+}
+
+// topic returns the broker of a topic, which is built if it does not exist yet.
+func (pubSub *PubSub) topic(topic string) (*broker.Broker[*message.Message], error) {
+	pubSub.mutex.Lock()
+	defer pubSub.mutex.Unlock()
+	if pubSub.closed {
+		return nil, ErrClosed
+	}
+	topicBroker, ok := pubSub.topics[topic]
+	if !ok {
+		topicBroker = pubSub.builder.Build()
+		pubSub.topics[topic] = topicBroker
+	}
+	return topicBroker, nil
+}
+
+// unsubscribe removes a client from the broker of a topic, unless the pub/sub is closing anyway.
+func (pubSub *PubSub) unsubscribe(topicBroker *broker.Broker[*message.Message], client broker.Client[*message.Message]) {
+	select {
+	case <-pubSub.closing:
+	default:
+		_ = topicBroker.Unsubscribe(client)
+	}
+}
+
+// deliver sends a copy of a message to the output channel until it is acknowledged.
+// Returns false if the context is done or the pub/sub is closed before.
+func (pubSub *PubSub) deliver(ctx context.Context, msg *message.Message, output chan<- *message.Message) bool {
+	for {
+		msgCopy := msg.Copy()
+		msgCtx, cancel := context.WithCancel(ctx)
+		msgCopy.SetContext(msgCtx)
+
+		acked, ok := pubSub.await(ctx, msgCopy, output)
+		cancel()
+		if !ok {
+			return false
+		}
+		if acked {
+			return true
+		}
+	}
+}
+
+// await sends a message to the output channel and waits for its acknowledgement.
+// Returns whether the message was acknowledged, and false as second value if the context is done
+// or the pub/sub is closed before.
+func (pubSub *PubSub) await(ctx context.Context, msg *message.Message, output chan<- *message.Message) (bool, bool) {
+	select {
+	case output <- msg:
+	case <-ctx.Done():
+		return false, false
+	case <-pubSub.closing:
+		return false, false
+	}
+	select {
+	case <-msg.Acked():
+		return true, true
+	case <-msg.Nacked():
+		return false, true
+	case <-ctx.Done():
+		return false, false
+	case <-pubSub.closing:
+		return false, false
+	}
+}
@@ -0,0 +1,105 @@
+package watermill
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/mpe85/go-broker"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+var (
+	_ message.Publisher  = (*PubSub)(nil)
+	_ message.Subscriber = (*PubSub)(nil)
+)
+
+func newPubSub() *PubSub {
+	return New(broker.NewBuilder[*message.Message]().Timeout(100 * time.Millisecond))
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	assertions := assert.New(t)
+
+	pubSub := newPubSub()
+	messages, err := pubSub.Subscribe(context.Background(), "topic")
+	assertions.Nil(err)
+	others, err := pubSub.Subscribe(context.Background(), "other")
+	assertions.Nil(err)
+
+	assertions.Nil(pubSub.Publish("topic", message.NewMessage("1", []byte("Hello")), message.NewMessage("2", nil)))
+
+	msg := <-messages
+	assertions.Equal("1", msg.UUID)
+	assertions.Equal(message.Payload("Hello"), msg.Payload)
+	assertions.True(msg.Ack())
+	assertions.Eventually(func() bool {
+		return msg.Context().Err() != nil
+	}, time.Second, 10*time.Millisecond)
+
+	msg = <-messages
+	assertions.Equal("2", msg.UUID)
+	assertions.True(msg.Ack())
+
+	select {
+	case <-others:
+		assertions.Fail("Received message not expected")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	assertions.Nil(pubSub.Close())
+	assertions.Nil(pubSub.Close())
+
+	_, ok := <-messages
+	assertions.False(ok)
+	_, ok = <-others
+	assertions.False(ok)
+
+	assertions.ErrorIs(pubSub.Publish("topic", message.NewMessage("3", nil)), ErrClosed)
+	_, err = pubSub.Subscribe(context.Background(), "topic")
+	assertions.ErrorIs(err, ErrClosed)
+}
+
+func TestNack(t *testing.T) {
+	assertions := assert.New(t)
+
+	pubSub := newPubSub()
+	messages, err := pubSub.Subscribe(context.Background(), "topic")
+	assertions.Nil(err)
+
+	assertions.Nil(pubSub.Publish("topic", message.NewMessage("1", nil)))
+
+	msg := <-messages
+	assertions.True(msg.Nack())
+	msg = <-messages
+	assertions.Equal("1", msg.UUID)
+	assertions.True(msg.Ack())
+
+	assertions.Nil(pubSub.Close())
+}
+
+func TestSubscribeContextDone(t *testing.T) {
+	assertions := assert.New(t)
+
+	pubSub := newPubSub()
+	ctx, cancel := context.WithCancel(context.Background())
+	messages, err := pubSub.Subscribe(ctx, "topic")
+	assertions.Nil(err)
+
+	assertions.Nil(pubSub.Publish("topic", message.NewMessage("1", nil)))
+	msg := <-messages
+	assertions.Equal("1", msg.UUID)
+
+	cancel()
+	_, ok := <-messages
+	assertions.False(ok)
+	assertions.ErrorIs(msg.Context().Err(), context.Canceled)
+
+	assertions.Nil(pubSub.Close())
+}